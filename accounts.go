@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrUsernameTaken   = errors.New("username is taken")
+)
+
+// PBKDF2-HMAC-SHA256 parameters for new password hashes. Iterations are stored
+// with each hash so they can be raised later without breaking old accounts
+const (
+	PASSWORD_ITERATIONS = 310000
+	PASSWORD_KEY_LENGTH = 32
+)
+
+// how long a session token signs its player in before they have to log in again
+const SESSION_LIFETIME = 30 * 24 * time.Hour
+
+// Account is a registered or guest player. Guests have no username or password
+// and can only sign in with the session token they were given
+type Account struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username,omitempty"`
+	DisplayName  string    `json:"displayName"`
+	Guest        bool      `json:"guest"`
+	PasswordHash string    `json:"passwordHash,omitempty"` // hex PBKDF2 output
+	PasswordSalt string    `json:"passwordSalt,omitempty"` // hex
+	Iterations   int       `json:"iterations,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// PlayerInfo is the public view of a player sent to other clients
+type PlayerInfo struct {
+	Number      int    `json:"number,omitempty"` // seat number, left out outside of a game
+	ID          string `json:"id,omitempty"`     // empty for anonymous connections
+	DisplayName string `json:"displayName"`
+}
+
+// AccountStore keeps accounts and the sessions that sign them in. Session
+// tokens are only ever stored hashed
+type AccountStore interface {
+	Create(account *Account) error
+	ByID(id string) (*Account, error)
+	ByUsername(username string) (*Account, error)
+	AddSession(token string, accountID string) error
+	BySession(token string) (*Account, error)
+}
+
+// accountStore is chosen by newAccountStore at startup
+var accountStore AccountStore = newJSONAccountStore("")
+
+func newAccountStore(cfg Config) (AccountStore, error) {
+	store := newJSONAccountStore(cfg.AccountFile)
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// pbkdf2SHA256 derives a key from password as described in RFC 8018
+func pbkdf2SHA256(password, salt []byte, iterations, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLength := prf.Size()
+	blocks := (keyLength + hashLength - 1) / hashLength
+
+	key := make([]byte, 0, blocks*hashLength)
+	u := make([]byte, hashLength)
+	var counter [4]byte
+
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		key = prf.Sum(key)
+
+		t := key[len(key)-hashLength:]
+		copy(u, t)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for j := range u {
+				t[j] ^= u[j]
+			}
+		}
+	}
+
+	return key[:keyLength]
+}
+
+// setPassword stores a fresh salted hash of password on the account
+func (a *Account) setPassword(password string) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+
+	a.PasswordSalt = hex.EncodeToString(salt)
+	a.Iterations = PASSWORD_ITERATIONS
+	a.PasswordHash = hex.EncodeToString(pbkdf2SHA256([]byte(password), salt, a.Iterations, PASSWORD_KEY_LENGTH))
+}
+
+func (a *Account) checkPassword(password string) bool {
+	if a.Guest || a.PasswordHash == "" {
+		return false
+	}
+
+	salt, err := hex.DecodeString(a.PasswordSalt)
+	if err != nil {
+		return false
+	}
+
+	expected, err := hex.DecodeString(a.PasswordHash)
+	if err != nil {
+		return false
+	}
+
+	actual := pbkdf2SHA256([]byte(password), salt, a.Iterations, len(expected))
+	return subtle.ConstantTimeCompare(actual, expected) == 1
+}
+
+// Session signs an account in until it expires
+type Session struct {
+	AccountID string    `json:"accountId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// jsonAccountStore keeps accounts in memory and, when given a path, rewrites
+// them to a JSON file after every change so they survive restarts
+type jsonAccountStore struct {
+	path     string
+	accounts map[string]*Account // id -> account
+	sessions map[string]Session  // hashed token -> session
+	lock     sync.RWMutex
+}
+
+// jsonAccountFile is the on-disk layout of a jsonAccountStore
+type jsonAccountFile struct {
+	Accounts []*Account         `json:"accounts"`
+	Sessions map[string]Session `json:"sessions"`
+}
+
+func newJSONAccountStore(path string) *jsonAccountStore {
+	return &jsonAccountStore{
+		path:     path,
+		accounts: make(map[string]*Account),
+		sessions: make(map[string]Session),
+	}
+}
+
+func (s *jsonAccountStore) load() error {
+	var file jsonAccountFile
+	if err := loadJSONFile(s.path, &file); err != nil {
+		return err
+	}
+
+	for _, account := range file.Accounts {
+		s.accounts[account.ID] = account
+	}
+
+	for token, session := range file.Sessions {
+		s.sessions[token] = session
+	}
+
+	return nil
+}
+
+// save writes the store to disk. Caller must hold s.lock
+func (s *jsonAccountStore) save() error {
+	file := jsonAccountFile{Sessions: s.sessions}
+	for _, account := range s.accounts {
+		file.Accounts = append(file.Accounts, account)
+	}
+
+	return saveJSONFile(s.path, file, 0600)
+}
+
+func (s *jsonAccountStore) Create(account *Account) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if account.Username != "" {
+		for _, existing := range s.accounts {
+			if existing.Username == account.Username {
+				return ErrUsernameTaken
+			}
+		}
+	}
+
+	s.accounts[account.ID] = account
+	return s.save()
+}
+
+func (s *jsonAccountStore) ByID(id string) (*Account, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	account, exists := s.accounts[id]
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+
+	return account, nil
+}
+
+func (s *jsonAccountStore) ByUsername(username string) (*Account, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, account := range s.accounts {
+		if account.Username != "" && account.Username == username {
+			return account, nil
+		}
+	}
+
+	return nil, ErrAccountNotFound
+}
+
+func (s *jsonAccountStore) AddSession(token string, accountID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+
+	// drop expired sessions so the file does not keep every login forever
+	for hashed, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, hashed)
+		}
+	}
+
+	s.sessions[hashToken(token)] = Session{AccountID: accountID, ExpiresAt: now.Add(SESSION_LIFETIME)}
+	return s.save()
+}
+
+func (s *jsonAccountStore) BySession(token string) (*Account, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	session, exists := s.sessions[hashToken(token)]
+	if !exists || !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrAccountNotFound
+	}
+
+	account, exists := s.accounts[session.AccountID]
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+
+	return account, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9_]{3,20}$`)
+
+const (
+	MIN_PASSWORD_LENGTH     = 8
+	MAX_DISPLAY_NAME_LENGTH = 24
+)
+
+// AuthRequest is the body of every /auth endpoint. Guests only send a display name
+type AuthRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	DisplayName string `json:"displayName"`
+}
+
+// AuthResponse hands the client the session token to connect with
+type AuthResponse struct {
+	Token  string     `json:"token"`
+	Player PlayerInfo `json:"player"`
+}
+
+type HTTPError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, HTTPError{Error: code, Message: message})
+}
+
+// cleanDisplayName trims a display name, falling back to fallback when empty.
+// It reports false if the name is too long
+func cleanDisplayName(name string, fallback string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fallback, true
+	}
+
+	return name, utf8.RuneCountInString(name) <= MAX_DISPLAY_NAME_LENGTH
+}
+
+// handleAuth serves POST /auth/register, /auth/login and /auth/guest
+func handleAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeHTTPError(w, http.StatusMethodNotAllowed, "methodNotAllowed", "use POST")
+		return
+	}
+
+	var req AuthRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, "malformedRequest", err.Error())
+		return
+	}
+
+	var account *Account
+	var status int
+	var code, message string
+
+	switch strings.TrimPrefix(r.URL.Path, "/auth/") {
+	case "register":
+		account, status, code, message = register(req)
+	case "login":
+		account, status, code, message = login(req)
+	case "guest":
+		account, status, code, message = registerGuest(req)
+	default:
+		writeHTTPError(w, http.StatusNotFound, "notFound", "unknown auth endpoint")
+		return
+	}
+
+	if account == nil {
+		writeHTTPError(w, status, code, message)
+		return
+	}
+
+	token := randomHex(32)
+	if err := accountStore.AddSession(token, account.ID); err != nil {
+		log.Printf("failed to save session for %s: %s\n", account.ID, err.Error())
+		writeHTTPError(w, http.StatusInternalServerError, "internalError", "could not create session")
+		return
+	}
+
+	writeJSON(w, status, AuthResponse{
+		Token:  token,
+		Player: PlayerInfo{ID: account.ID, DisplayName: account.DisplayName},
+	})
+}
+
+func register(req AuthRequest) (*Account, int, string, string) {
+	if !validUsername.MatchString(req.Username) {
+		return nil, http.StatusBadRequest, "invalidUsername", "usernames are 3 to 20 letters, digits or underscores"
+	}
+
+	if utf8.RuneCountInString(req.Password) < MIN_PASSWORD_LENGTH {
+		return nil, http.StatusBadRequest, "invalidPassword", fmt.Sprintf("passwords need at least %d characters", MIN_PASSWORD_LENGTH)
+	}
+
+	displayName, ok := cleanDisplayName(req.DisplayName, req.Username)
+	if !ok {
+		return nil, http.StatusBadRequest, "invalidDisplayName", fmt.Sprintf("display names are at most %d characters", MAX_DISPLAY_NAME_LENGTH)
+	}
+
+	account := &Account{
+		ID:          randomHex(12),
+		Username:    req.Username,
+		DisplayName: displayName,
+		CreatedAt:   time.Now().UTC(),
+	}
+	account.setPassword(req.Password)
+
+	if err := accountStore.Create(account); err == ErrUsernameTaken {
+		return nil, http.StatusConflict, "usernameTaken", "that username is taken"
+	} else if err != nil {
+		log.Printf("failed to create account %s: %s\n", req.Username, err.Error())
+		return nil, http.StatusInternalServerError, "internalError", "could not create account"
+	}
+
+	return account, http.StatusCreated, "", ""
+}
+
+func login(req AuthRequest) (*Account, int, string, string) {
+	account, err := accountStore.ByUsername(req.Username)
+	if err != nil || !account.checkPassword(req.Password) {
+		return nil, http.StatusUnauthorized, "invalidCredentials", "wrong username or password"
+	}
+
+	return account, http.StatusOK, "", ""
+}
+
+func registerGuest(req AuthRequest) (*Account, int, string, string) {
+	id := randomHex(12)
+
+	displayName, ok := cleanDisplayName(req.DisplayName, "Guest "+id[:6])
+	if !ok {
+		return nil, http.StatusBadRequest, "invalidDisplayName", fmt.Sprintf("display names are at most %d characters", MAX_DISPLAY_NAME_LENGTH)
+	}
+
+	account := &Account{
+		ID:          id,
+		DisplayName: displayName,
+		Guest:       true,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	if err := accountStore.Create(account); err != nil {
+		log.Printf("failed to create guest account: %s\n", err.Error())
+		return nil, http.StatusInternalServerError, "internalError", "could not create account"
+	}
+
+	return account, http.StatusCreated, "", ""
+}
+
+// handleAuthenticate binds the connection to the account a session token belongs to
+func handleAuthenticate(c *WSClient, msg AuthenticateMessage) error {
+	// a seat keeps the identity it was taken with, but finished games do not count
+	if c.seated() {
+		return &ProtocolError{Code: "alreadyInGame", Message: "authenticate before creating or joining a game"}
+	}
+
+	account, err := accountStore.BySession(msg.Token)
+	if err != nil {
+		return &ProtocolError{Code: "invalidToken", Message: "unknown or expired session token"}
+	}
+
+	c.Account = account
+
+	c.WriteJSON(AuthenticatedMessage{
+		Type:   "authenticated",
+		Player: PlayerInfo{ID: account.ID, DisplayName: account.DisplayName},
+	})
+
+	return nil
+}
@@ -30,4 +30,20 @@ var BOGGLE_BIG = []string{
     "CEIILT", "CEILPT", "CEIPST", "DDHNOT", "DHHLOR",
     "DHLNOR", "DHLNOR", "EIIITT", "EMOTTT", "ENSSSU",
     "FIPRSY", "GORRVW", "IPRRRY", "NOOTUW", "OOOTTU",
-}
\ No newline at end of file
+}
+
+// BoardVersion describes a board size clients can ask for and the dice it is rolled from
+type BoardVersion struct {
+    Size     int
+    DiceSets [][]string // one set is picked at random per game
+}
+
+const DEFAULT_BOARD = "classic"
+
+// BOARD_VERSIONS maps the name a client can request to its board version.
+// BOGGLE_BIG only has 25 dice, so a 6x6 board rolls some of them twice
+var BOARD_VERSIONS = map[string]BoardVersion{
+    "classic": {Size: 4, DiceSets: [][]string{BOGGLE_1992, BOGGLE_1983}},
+    "master":  {Size: 5, DiceSets: [][]string{BOGGLE_MASTER}},
+    "big":     {Size: 6, DiceSets: [][]string{BOGGLE_BIG}},
+}
@@ -0,0 +1,17 @@
+package boards
+
+// DefaultDictionary is used when a client does not ask for a specific dictionary
+const DefaultDictionary = "common"
+
+// Dictionaries maps the name a client can request to its word list
+var Dictionaries = map[string]map[string]bool{
+	"common":      Common,
+	"nursery":     Nursery,
+	"shakespeare": Shakespeare,
+}
+
+// IsKnown reports whether name refers to a registered dictionary
+func IsKnown(name string) bool {
+	_, ok := Dictionaries[name]
+	return ok
+}
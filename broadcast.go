@@ -1,8 +1,42 @@
 package main
 
-import "fmt"
+import "time"
+
+// broadcastToRoom sends the same message to every connected player and spectator
+// in the room. Caller must not hold room.RoomLock
+func broadcastToRoom(room *Room, message interface{}) {
+	for _, client := range recipients(room, 0) {
+		client.WriteJSON(message)
+	}
+}
+
+// recipients returns everyone in the room except player, or everyone if player
+// is 0. Seats and spectators change under room.RoomLock, so they are copied out
+// rather than written to while the lock is held
+func recipients(room *Room, player int) []*WSClient {
+	room.RoomLock.Lock()
+	defer room.RoomLock.Unlock()
+
+	clients := make([]*WSClient, 0, len(room.Seats)+len(room.Spectators))
+
+	for i, seat := range room.Seats {
+		if i+1 != player && seat.Client != nil {
+			clients = append(clients, seat.Client)
+		}
+	}
+
+	if player == 0 {
+		clients = append(clients, room.Spectators...)
+	}
+
+	return clients
+}
+
+func broadcastEndGame(room *Room) {
+	if room.StopTimer != nil {
+		room.StopTimer()
+	}
 
-func broadcastEndGame(room *Room, player1 float64, player2 float64) {
 	// delete room first, then send endgame to clients
 	clientRoomsLock.Lock()
 
@@ -10,41 +44,67 @@ func broadcastEndGame(room *Room, player1 float64, player2 float64) {
 
 	clientRoomsLock.Unlock()
 
-	gameOverMessage := "GAME OVER!"
+	reason, player := "allWordsFound", 0
 
-	if room.Player1MissedTurns == 3 {
-		gameOverMessage = gameOverMessage + "\nPlayer 1 Missed 3 Consecutive Turns"
-	} else if room.Player2MissedTurns == 3 {
-		gameOverMessage = gameOverMessage + "\nPlayer 2 Missed 3 Consecutive Turns"
-	} else {
-		gameOverMessage = gameOverMessage + "\nAll possible words found!"
+	if room.Mode == MODE_SIMULTANEOUS {
+		reason = "timeUp"
+	} else if player = missedTurnsPlayer(room); player != 0 {
+		reason = "missedTurns"
 	}
 
-	sendMessage(room, gameOverMessage)
+	recordStats(room)
+	updateRatings(room)
 
-	if room.Player1WS != nil {
-		room.Player1WS.Conn.WriteJSON(map[string]interface{}{
-			"type":    "endgame",
-			"player1": player1,
-			"player2": player2,
-		})
+	series := room.Series
+	if series == nil {
+		series = newSeries(roomSettings(room))
 	}
-	
-	if room.Player2WS != nil {
-		room.Player2WS.Conn.WriteJSON(map[string]interface{}{
-			"type":    "endgame",
-			"player1": player1,
-			"player2": player2,
-		})
+
+	series.record(room)
+
+	room.RoomLock.Lock()
+
+	publishEvent(room, EVENT_GAME_ENDED, player, GameEndedEvent{
+		Reason:  reason,
+		Scores:  scores(room),
+		Players: players(room),
+	})
+
+	room.RoomLock.Unlock()
+
+	endGame := EndGameMessage{
+		Type:    "endgame",
+		Scores:  scores(room),
+		Players: players(room),
 	}
-	
-	if room.KafkaWriter != nil {
-		deleteTopic(room.RoomName)
-		room.KafkaWriter.Close()
+
+	if room.Mode == MODE_SIMULTANEOUS {
+		endGame.Words = room.Breakdown
 	}
+
+	if room.Series != nil {
+		endGame.Series = series.score()
+	}
+
+	broadcastToRoom(room, endGame)
+
+	if series.bestOf() {
+		update := SeriesUpdateMessage{Type: "seriesUpdate", Series: series.score()}
+		if !update.Series.Finished {
+			update.NextBoardIn = int(SERIES_BREAK / time.Second)
+		}
+
+		broadcastToRoom(room, update)
+	}
+
+	offerRematch(room, series)
+	
+	eventSink.CloseRoom(room.RoomName)
 }
 
-func broadcastDisconnect(roomName string) {
+// broadcastDisconnect aborts the game in the room. player is the one who did not
+// come back in time and loses the game, or 0 if the game never started
+func broadcastDisconnect(roomName string, player int) {
 	clientRoomsLock.RLock()
 	defer clientRoomsLock.RUnlock()
 
@@ -53,22 +113,24 @@ func broadcastDisconnect(roomName string) {
 		return
 	}
 
-	room.Player1WS.Conn.WriteJSON(map[string]interface{}{
-		"type": "disconnected",
-	})
-	room.Player2WS.Conn.WriteJSON(map[string]interface{}{
-		"type": "disconnected",
-	})
+	broadcastToRoom(room, StatusMessage{Type: "disconnected"})
 
-	sendMessage(room, "Someone disconnected! This game has ended.")
-	
-	if room.KafkaWriter != nil {
-		deleteTopic(room.RoomName)
-		room.KafkaWriter.Close()
+	if room.StopTimer != nil {
+		room.StopTimer()
+	}
+
+	if player != 0 {
+		forfeitRatings(room, player)
 	}
+
+	room.RoomLock.Lock()
+	publishEvent(room, EVENT_GAME_ABORTED, player, GameAbortedEvent{Reason: "disconnect"})
+	room.RoomLock.Unlock()
+	
+	eventSink.CloseRoom(room.RoomName)
 }
 
-func broadcastSwitch(roomName string, curr_player int, next_player int, word string) {
+func broadcastSwitch(roomName string, next_player int, word string, path []Tile) {
 	clientRoomsLock.RLock()
 	defer clientRoomsLock.RUnlock()
 
@@ -77,25 +139,43 @@ func broadcastSwitch(roomName string, curr_player int, next_player int, word str
 		return
 	}
 
-	room.Player1WS.Conn.WriteJSON(map[string]interface{}{
-		"type":   "switch",
-		"player": next_player,
-		"word":   word,
-	})
-	room.Player2WS.Conn.WriteJSON(map[string]interface{}{
-		"type":   "switch",
-		"player": next_player,
-		"word":   word,
+	broadcastToRoom(room, SwitchMessage{
+		Type:   "switch",
+		Player: next_player,
+		Word:   word,
+		Path:   path,
+		Scores: scores(room),
 	})
+}
+
+func broadcastStart(roomName string) {
+	clientRoomsLock.RLock()
+	defer clientRoomsLock.RUnlock()
 
-	if word == "" {
-		sendMessage(room, fmt.Sprintf("Player %d could not find word. Switching from Player %d to %d", curr_player, curr_player, next_player))
-	} else {
-		sendMessage(room, fmt.Sprintf("Player %d found word %s. Switching from Player %d to %d", curr_player, word, curr_player, next_player))
+	room, exists := clientRooms[roomName]
+	if !exists {
+		return
 	}
+
+	room.RoomLock.Lock()
+
+	// published first so it is ordered before anything the players do next
+	publishEvent(room, EVENT_GAME_STARTED, 0, nil)
+
+	start := StartMessage{
+		Type:      "start",
+		Countdown: room.Countdown,
+		Seed:      room.Seed,
+		Players:   players(room),
+		GameInfo:  gameInfo(room),
+	}
+
+	room.RoomLock.Unlock()
+
+	broadcastToRoom(room, start)
 }
 
-func broadcastStart(roomName string) {
+func broadcastTick(roomName string, player int, countdown [2]int) {
 	clientRoomsLock.RLock()
 	defer clientRoomsLock.RUnlock()
 
@@ -104,16 +184,30 @@ func broadcastStart(roomName string) {
 		return
 	}
 
-	room.Player1WS.Conn.WriteJSON(map[string]interface{}{
-		"type":      "start",
-		"countdown": [2]int{3, 0},
-		"gameInfo":  *room,
-	})
-	room.Player2WS.Conn.WriteJSON(map[string]interface{}{
-		"type":      "start",
-		"countdown": [2]int{3, 0},
-		"gameInfo":  *room,
+	broadcastToRoom(room, TickMessage{
+		Type:      "tick",
+		Player:    player,
+		Countdown: countdown,
 	})
+}
 
-	sendMessage(room, "Game start!")
-}
\ No newline at end of file
+// broadcastOpponentStatus tells the other players in the room that player
+// dropped or came back
+func broadcastOpponentStatus(room *Room, player int, status string) {
+	for _, client := range recipients(room, player) {
+		client.WriteJSON(PlayerStatusMessage{
+			Type:   status,
+			Player: player,
+			Grace:  int(RESUME_GRACE / time.Second),
+		})
+	}
+}
+
+// broadcastSpectators lets everyone in the room know how many people are watching
+func broadcastSpectators(room *Room, count int) {
+	broadcastToRoom(room, SpectatorsMessage{
+		Type:  "spectators",
+		Count: count,
+		Max:   MAX_SPECTATORS,
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config holds the settings chosen at startup. Every flag can also be set
+// through the environment variable named in its usage string
+type Config struct {
+	EventSink          string // none, stdout, file, kafka or memory
+	EventFile          string // path the file sink appends to
+	KafkaBrokers       []string
+	KafkaTopic         string // topic shared by every room
+	KafkaTopicPerRoom  bool   // legacy layout with one topic per room
+	KafkaTopicPrefix   string // prepended to the room name to form its topic with KafkaTopicPerRoom
+	KafkaAcks          string // all, one or none
+	KafkaTLS           bool
+	KafkaSASLUser      string // enables SASL/PLAIN when set
+	KafkaSASLPassword  string
+	ReplayStore        string        // memory or file
+	ReplayDir          string        // directory the file replay store writes to
+	AccountFile        string        // accounts are only kept in memory when empty
+	StatsFile          string        // game results are only kept in memory when empty
+	RatingFile         string        // ratings are only kept in memory when empty
+	MatchmakingTimeout time.Duration // longest wait in the random game queue, 0 for no limit
+}
+
+func envOr(name string, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+
+	return fallback
+}
+
+func loadConfig() Config {
+	var cfg Config
+	var brokers string
+
+	flag.StringVar(&cfg.EventSink, "event-sink", envOr("BOGGLE_EVENT_SINK", "none"), "where game events go: none, stdout, file, kafka or memory (BOGGLE_EVENT_SINK)")
+	flag.StringVar(&cfg.EventFile, "event-file", envOr("BOGGLE_EVENT_FILE", "events.log"), "file the file sink appends to (BOGGLE_EVENT_FILE)")
+	flag.StringVar(&brokers, "kafka-brokers", envOr("BOGGLE_KAFKA_BROKERS", "localhost:9094"), "comma separated Kafka brokers (BOGGLE_KAFKA_BROKERS)")
+	flag.StringVar(&cfg.KafkaTopic, "kafka-topic", envOr("BOGGLE_KAFKA_TOPIC", "boggle-events"), "topic every room's events go to (BOGGLE_KAFKA_TOPIC)")
+	flag.BoolVar(&cfg.KafkaTopicPerRoom, "kafka-topic-per-room", envOr("BOGGLE_KAFKA_TOPIC_PER_ROOM", "") == "true", "legacy mode: give every room its own topic (BOGGLE_KAFKA_TOPIC_PER_ROOM)")
+	flag.StringVar(&cfg.KafkaTopicPrefix, "kafka-topic-prefix", envOr("BOGGLE_KAFKA_TOPIC_PREFIX", ""), "prefix for per-room topic names in legacy mode (BOGGLE_KAFKA_TOPIC_PREFIX)")
+	flag.StringVar(&cfg.KafkaAcks, "kafka-acks", envOr("BOGGLE_KAFKA_ACKS", "all"), "acks required from Kafka: all, one or none (BOGGLE_KAFKA_ACKS)")
+	flag.BoolVar(&cfg.KafkaTLS, "kafka-tls", envOr("BOGGLE_KAFKA_TLS", "") == "true", "connect to Kafka over TLS (BOGGLE_KAFKA_TLS)")
+	flag.StringVar(&cfg.KafkaSASLUser, "kafka-sasl-user", envOr("BOGGLE_KAFKA_SASL_USER", ""), "SASL/PLAIN username for Kafka (BOGGLE_KAFKA_SASL_USER)")
+	flag.StringVar(&cfg.KafkaSASLPassword, "kafka-sasl-password", envOr("BOGGLE_KAFKA_SASL_PASSWORD", ""), "SASL/PLAIN password for Kafka (BOGGLE_KAFKA_SASL_PASSWORD)")
+
+	flag.StringVar(&cfg.ReplayStore, "replay-store", envOr("BOGGLE_REPLAY_STORE", "memory"), "where finished games are kept for replay: memory or file (BOGGLE_REPLAY_STORE)")
+	flag.StringVar(&cfg.ReplayDir, "replay-dir", envOr("BOGGLE_REPLAY_DIR", "replays"), "directory the file replay store writes to (BOGGLE_REPLAY_DIR)")
+	flag.StringVar(&cfg.AccountFile, "account-file", envOr("BOGGLE_ACCOUNT_FILE", ""), "JSON file player accounts are saved to, in memory only if empty (BOGGLE_ACCOUNT_FILE)")
+	flag.StringVar(&cfg.StatsFile, "stats-file", envOr("BOGGLE_STATS_FILE", ""), "JSON file game results are saved to, in memory only if empty (BOGGLE_STATS_FILE)")
+	flag.StringVar(&cfg.RatingFile, "rating-file", envOr("BOGGLE_RATING_FILE", ""), "JSON file player ratings are saved to, in memory only if empty (BOGGLE_RATING_FILE)")
+
+	timeout, err := time.ParseDuration(envOr("BOGGLE_MATCHMAKING_TIMEOUT", DEFAULT_MATCHMAKING_TIMEOUT.String()))
+	if err != nil {
+		log.Fatalf("invalid BOGGLE_MATCHMAKING_TIMEOUT: %s\n", err.Error())
+	}
+
+	flag.DurationVar(&cfg.MatchmakingTimeout, "matchmaking-timeout", timeout, "longest wait in the random game queue before giving up, 0 for no limit (BOGGLE_MATCHMAKING_TIMEOUT)")
+
+	flag.Parse()
+
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			cfg.KafkaBrokers = append(cfg.KafkaBrokers, broker)
+		}
+	}
+
+	return cfg
+}
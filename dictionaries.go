@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go_boggle_server/boards"
+	"go_boggle_server/trie"
+)
+
+// dictionaryTries holds one trie per entry in boards.Dictionaries. It is built
+// once by loadDictionaries before the server accepts connections and is only
+// read afterwards, so no lock is needed
+var dictionaryTries = make(map[string]trie.Lookup)
+
+func loadDictionaries() {
+	for name, words := range boards.Dictionaries {
+		start := time.Now()
+
+		t := trie.NewTrie()
+		for word := range words {
+			t.Add(word)
+		}
+
+		dictionaryTries[name] = t
+
+		fmt.Printf("built %s dictionary: %d words, %d nodes in %s\n", name, len(words), t.NodeCount(), time.Since(start))
+	}
+}
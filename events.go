@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EVENT_SCHEMA_VERSION is bumped whenever the shape of GameEvent or any of its
+// payloads changes in a way consumers have to know about
+const EVENT_SCHEMA_VERSION = 1
+
+// event types
+const (
+	EVENT_GAME_CREATED        = "GameCreated"
+	EVENT_PLAYER_JOINED       = "PlayerJoined"
+	EVENT_GAME_STARTED        = "GameStarted"
+	EVENT_WORD_ACCEPTED       = "WordAccepted"
+	EVENT_WORD_REJECTED       = "WordRejected"
+	EVENT_TURN_SKIPPED        = "TurnSkipped"
+	EVENT_PLAYER_DISCONNECTED = "PlayerDisconnected"
+	EVENT_PLAYER_RESUMED      = "PlayerResumed"
+	EVENT_GAME_ENDED          = "GameEnded"
+	EVENT_GAME_ABORTED        = "GameAborted"
+)
+
+// GameEvent is one entry in a room's event stream. Sequence starts at 1 and
+// increases by one for every event in the room, so consumers can spot gaps
+type GameEvent struct {
+	Version   int         `json:"version"`
+	Type      string      `json:"type"`
+	Room      string      `json:"room"`
+	Sequence  int64       `json:"sequence"`
+	Timestamp time.Time   `json:"timestamp"`
+	Player    int         `json:"player,omitempty"` // 0 when the event is not about one player
+	Data      interface{} `json:"data,omitempty"`   // one of the *Event payloads below
+}
+
+type GameCreatedEvent struct {
+	Letters    []string `json:"letters"` // the board, row by row
+	Dictionary string   `json:"dictionary"`
+	Board      string   `json:"board"`
+	Size       int      `json:"size"`
+	Seed       int64    `json:"seed"`
+	Mode       string   `json:"mode"`
+	Capacity   int      `json:"capacity"`
+	TotalScore int      `json:"totalScore"`
+	Private    bool     `json:"private,omitempty"` // private games are left out of replays
+}
+
+type WordEvent struct {
+	Word   string `json:"word"`
+	Path   []Tile `json:"path,omitempty"`
+	Points int    `json:"points,omitempty"`
+	Reason string `json:"reason,omitempty"` // why a word was rejected
+}
+
+type TurnSkippedEvent struct {
+	Next int `json:"next"` // player whose turn it is now
+}
+
+type GameEndedEvent struct {
+	Reason  string       `json:"reason"` // allWordsFound, missedTurns or timeUp
+	Scores  []float64    `json:"scores"`
+	Players []PlayerInfo `json:"players"`
+}
+
+type GameAbortedEvent struct {
+	Reason string `json:"reason"`
+}
+
+// publishEvent stamps an event for the room and hands it to the event sink.
+// Caller must hold room.RoomLock, so the room's events are numbered in the
+// order its game changed, unless the room is not shared with anyone yet
+func publishEvent(room *Room, eventType string, player int, data interface{}) {
+	eventSink.Publish(GameEvent{
+		Version:   EVENT_SCHEMA_VERSION,
+		Type:      eventType,
+		Room:      room.RoomName,
+		Sequence:  atomic.AddInt64(&room.EventSequence, 1),
+		Timestamp: time.Now().UTC(),
+		Player:    player,
+		Data:      data,
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import "math/rand"
+
+// BoardGenerator produces the letters of a new board, row by row, for the
+// given board version. Each entry is a single tile ("Qu" counts as one tile).
+// All randomness must come from rng so that a seed always gives the same board
+type BoardGenerator interface {
+	Generate(version BoardVersion, rng *rand.Rand) []string
+}
+
+// boardGenerator is the generator used by initGame
+var boardGenerator BoardGenerator = DiceGenerator{}
+
+// DiceGenerator mimics shaking a real Boggle tray: the dice are shuffled into
+// random positions and then each one lands on a random face
+type DiceGenerator struct{}
+
+func (DiceGenerator) Generate(version BoardVersion, rng *rand.Rand) []string {
+	size := version.Size
+	chosenBoggle := version.DiceSets[rng.Intn(len(version.DiceSets))]
+
+	tiles := make([]string, size*size)
+	for i, index := range shuffleDice(len(tiles), len(chosenBoggle), rng) {
+		die := chosenBoggle[index]
+		randIndex := rng.Intn(len(die))
+		char := die[randIndex : randIndex+1]
+		if char == "Q" {
+			char += "u"
+		}
+		tiles[i] = char
+	}
+
+	return tiles
+}
+
+// shuffleDice returns the index in the dice set of the die that lands on each
+// of the tiles. Sets with fewer dice than tiles reuse dice from the start of the set
+func shuffleDice(tiles int, dice int, rng *rand.Rand) []int {
+	positions := make([]int, tiles)
+	for i := range positions {
+		positions[i] = i % dice
+	}
+
+	rng.Shuffle(len(positions), func(i, j int) {
+		positions[i], positions[j] = positions[j], positions[i]
+	})
+
+	return positions
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// chi-square critical values at p = 0.001, by degrees of freedom
+var chiSquare001 = map[int]float64{
+	15: 37.697,
+	24: 51.179,
+	35: 66.619,
+}
+
+// TestShuffleDiceUniform rolls seeded boards and checks, for every cell, that
+// each die is as likely as any other to land there
+func TestShuffleDiceUniform(t *testing.T) {
+	const boards = 20000
+
+	for name, version := range BOARD_VERSIONS {
+		tiles := version.Size * version.Size
+		dice := tiles
+		if len(version.DiceSets[0]) < dice {
+			dice = len(version.DiceSets[0])
+		}
+
+		// counts[cell][die] is how often the die landed on the cell
+		counts := make([][]int, tiles)
+		for cell := range counts {
+			counts[cell] = make([]int, dice)
+		}
+
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < boards; i++ {
+			for cell, die := range shuffleDice(tiles, dice, rng) {
+				counts[cell][die]++
+			}
+		}
+
+		critical, ok := chiSquare001[dice-1]
+		if !ok {
+			t.Fatalf("%s: no critical value for %d degrees of freedom", name, dice-1)
+		}
+
+		for cell, byDie := range counts {
+			// dice reused on a big board land twice as often
+			total := 0
+			expected := make([]float64, dice)
+			for die := range expected {
+				copies := tiles / dice
+				if die < tiles%dice {
+					copies++
+				}
+
+				expected[die] = float64(boards*copies) / float64(tiles)
+				total += copies
+			}
+
+			if total != tiles {
+				t.Fatalf("%s: expected counts cover %d tiles, not %d", name, total, tiles)
+			}
+
+			statistic := 0.0
+			for die, observed := range byDie {
+				diff := float64(observed) - expected[die]
+				statistic += diff * diff / expected[die]
+			}
+
+			if statistic > critical {
+				t.Errorf("%s: cell %d is not uniform, chi-square %.2f > %.2f", name, cell, statistic, critical)
+			}
+		}
+	}
+}
+
+func TestGenerateIsSeeded(t *testing.T) {
+	for name, version := range BOARD_VERSIONS {
+		first := DiceGenerator{}.Generate(version, rand.New(rand.NewSource(42)))
+		second := DiceGenerator{}.Generate(version, rand.New(rand.NewSource(42)))
+
+		if len(first) != version.Size*version.Size {
+			t.Fatalf("%s: got %d tiles, want %d", name, len(first), version.Size*version.Size)
+		}
+
+		for i := range first {
+			if first[i] != second[i] {
+				t.Fatalf("%s: the same seed gave different boards", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go_boggle_server/boards"
+
+	"github.com/gorilla/websocket"
+)
+
+// range of protocol versions this server speaks. Clients that never send hello
+// are treated as MIN_PROTOCOL_VERSION
+const (
+	MIN_PROTOCOL_VERSION = 1
+	MAX_PROTOCOL_VERSION = 1
+)
+
+// close code sent to clients asking for a protocol version we do not speak
+const CLOSE_UNSUPPORTED_VERSION = 4001
+
+// SERVER_FEATURES are the optional parts of the protocol this server supports
+var SERVER_FEATURES = []string{
+	"accounts",
+	"boardSizes",
+	"dictionaries",
+	"matchmaking",
+	"modes",
+	"multiplayer",
+	"privateRooms",
+	"queueTimeout",
+	"rematch",
+	"replay",
+	"resume",
+	"seeds",
+	"series",
+	"spectate",
+	"stats",
+	"tilePaths",
+	"turnTimer",
+}
+
+// features every client gets whether or not it asked for them, since the server
+// cannot leave them out for just one connection
+var ALWAYS_ON_FEATURES = []string{
+	"queueTimeout",
+	"stats",
+	"turnTimer",
+}
+
+// messageFeatures maps message types to the feature that has to be enabled to
+// send them
+var messageFeatures = map[string]string{
+	"authenticate":   "accounts",
+	"randomGame":     "matchmaking",
+	"cancelRandom":   "matchmaking",
+	"requestRematch": "rematch",
+	"acceptRematch":  "rematch",
+	"spectateGame":   "spectate",
+	"resume":         "resume",
+	"replay":         "replay",
+}
+
+func handleHello(c *WSClient, msg HelloMessage) error {
+	if c.ProtocolVersion != 0 {
+		return &ProtocolError{Code: "duplicateHello", Message: "hello was already sent on this connection"}
+	}
+
+	if msg.Version < MIN_PROTOCOL_VERSION || msg.Version > MAX_PROTOCOL_VERSION {
+		reason := fmt.Sprintf("protocol version %d is not supported, use %d to %d", msg.Version, MIN_PROTOCOL_VERSION, MAX_PROTOCOL_VERSION)
+
+		c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(CLOSE_UNSUPPORTED_VERSION, reason), time.Now().Add(time.Second))
+		c.Conn.Close()
+
+		fmt.Printf("%d asked for unsupported protocol version %d\n", c.UniqueNumber, msg.Version)
+		return nil
+	}
+
+	c.ProtocolVersion = msg.Version
+	c.Features = enabledFeatures(msg.Features)
+
+	c.WriteJSON(WelcomeMessage{
+		Type:         "welcome",
+		MinVersion:   MIN_PROTOCOL_VERSION,
+		MaxVersion:   MAX_PROTOCOL_VERSION,
+		Version:      c.ProtocolVersion,
+		Features:     c.Features,
+		Dictionaries: dictionaryNames(),
+		Boards:       boardSizes(),
+		Modes:        modeNames(),
+		MinPlayers:   MIN_PLAYERS,
+		MaxPlayers:   MAX_PLAYERS,
+	})
+
+	return nil
+}
+
+// enabledFeatures returns the features both sides support, plus the ones that
+// are always on. A client that does not list any features gets everything the
+// server has
+func enabledFeatures(requested []string) []string {
+	if len(requested) == 0 {
+		return SERVER_FEATURES
+	}
+
+	enabled := []string{}
+	for _, feature := range SERVER_FEATURES {
+		if contains(requested, feature) || contains(ALWAYS_ON_FEATURES, feature) {
+			enabled = append(enabled, feature)
+		}
+	}
+
+	return enabled
+}
+
+// hasFeature reports whether feature is enabled for c. Clients that never sent
+// hello get every feature, as they did before the handshake existed
+func (c *WSClient) hasFeature(feature string) bool {
+	return c.ProtocolVersion == 0 || contains(c.Features, feature)
+}
+
+func (c *WSClient) requireFeature(feature string) error {
+	if c.hasFeature(feature) {
+		return nil
+	}
+
+	return &ProtocolError{Code: "featureNotEnabled", Message: fmt.Sprintf("the %s feature was not enabled in hello", feature)}
+}
+
+// checkOptions makes sure every game option that is set belongs to an enabled feature
+func (c *WSClient) checkOptions(o GameOptions) error {
+	required := []string{}
+
+	if o.Dictionary != "" {
+		required = append(required, "dictionaries")
+	}
+	if o.Board != "" {
+		required = append(required, "boardSizes")
+	}
+	if o.Mode != "" {
+		required = append(required, "modes")
+	}
+	if o.Players != nil {
+		required = append(required, "multiplayer")
+	}
+
+	for _, feature := range required {
+		if err := c.requireFeature(feature); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dictionaryNames() []string {
+	names := make([]string, 0, len(boards.Dictionaries))
+	for name := range boards.Dictionaries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// boardSizes maps every board version to its side length
+func boardSizes() map[string]int {
+	sizes := make(map[string]int, len(BOARD_VERSIONS))
+	for name, version := range BOARD_VERSIONS {
+		sizes[name] = version.Size
+	}
+
+	return sizes
+}
+
+func modeNames() []string {
+	names := make([]string, 0, len(MODES))
+	for name := range MODES {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// loadJSONFile decodes the file at path into v. A store with no path, or whose
+// file does not exist yet, starts out empty
+func loadJSONFile(path string, v interface{}) error {
+	if path == "" {
+		return nil
+	}
+
+	encoded, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, v)
+}
+
+// saveJSONFile writes v to path as JSON, or does nothing if path is empty
+func saveJSONFile(path string, v interface{}, perm os.FileMode) error {
+	if path == "" {
+		return nil
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// write then rename so a crash never leaves half a file behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, encoded, perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+// kafkaWriter is the part of kafka.Writer the sink uses
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaBroker hands out writers for topics. remoteBroker talks to a real
+// cluster and memoryBroker keeps everything in process
+type kafkaBroker interface {
+	Writer(topic string) kafkaWriter
+	DeleteTopic(topic string)
+}
+
+// kafkaSink publishes every room's events keyed by room name. By default all
+// rooms share one long-lived writer on a single topic. With topic-per-room
+// (the legacy layout) each room gets its own topic, deleted when the room closes
+type kafkaSink struct {
+	broker       kafkaBroker
+	topicPerRoom bool
+	topicPrefix  string
+	shared       kafkaWriter
+	writers      map[string]kafkaWriter // only used with topicPerRoom
+	lock         sync.Mutex
+}
+
+func newKafkaSink(cfg Config, broker kafkaBroker) *kafkaSink {
+	sink := &kafkaSink{
+		broker:       broker,
+		topicPerRoom: cfg.KafkaTopicPerRoom,
+		topicPrefix:  cfg.KafkaTopicPrefix,
+		writers:      make(map[string]kafkaWriter),
+	}
+
+	if !sink.topicPerRoom {
+		sink.shared = broker.Writer(cfg.KafkaTopic)
+	}
+
+	return sink
+}
+
+func (s *kafkaSink) topic(room string) string {
+	return s.topicPrefix + room
+}
+
+func (s *kafkaSink) OpenRoom(room string) {
+	if !s.topicPerRoom {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.writers[room] = s.broker.Writer(s.topic(room))
+}
+
+func (s *kafkaSink) Publish(event GameEvent) {
+	writer := s.shared
+
+	if s.topicPerRoom {
+		s.lock.Lock()
+		writer = s.writers[event.Room]
+		s.lock.Unlock()
+	}
+
+	if writer == nil {
+		return
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode %s event: %s\n", event.Type, err.Error())
+		return
+	}
+
+	// keying by room keeps a room's events in order on one partition
+	writer.WriteMessages(
+		context.Background(),
+		kafka.Message{
+			Key:   []byte(event.Room),
+			Value: encoded,
+		},
+	)
+}
+
+func (s *kafkaSink) CloseRoom(room string) {
+	if !s.topicPerRoom {
+		return
+	}
+
+	s.lock.Lock()
+	writer, exists := s.writers[room]
+	delete(s.writers, room)
+	s.lock.Unlock()
+
+	if !exists {
+		return
+	}
+
+	// closing flushes anything still buffered, so the topic goes after that
+	go func() {
+		writer.Close()
+		s.broker.DeleteTopic(s.topic(room))
+	}()
+}
+
+func (s *kafkaSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for room, writer := range s.writers {
+		writer.Close()
+		delete(s.writers, room)
+	}
+
+	if s.shared != nil {
+		return s.shared.Close()
+	}
+
+	return nil
+}
+
+// remoteBroker connects to the Kafka cluster from the config
+type remoteBroker struct {
+	brokers   []string
+	acks      kafka.RequiredAcks
+	transport *kafka.Transport
+	dialer    *kafka.Dialer
+}
+
+func newRemoteBroker(cfg Config) (*remoteBroker, error) {
+	if len(cfg.KafkaBrokers) == 0 {
+		return nil, fmt.Errorf("kafka event sink needs at least one broker")
+	}
+
+	acks := map[string]kafka.RequiredAcks{
+		"all":  kafka.RequireAll,
+		"one":  kafka.RequireOne,
+		"none": kafka.RequireNone,
+	}
+
+	required, ok := acks[cfg.KafkaAcks]
+	if !ok {
+		return nil, fmt.Errorf("unknown kafka acks %q", cfg.KafkaAcks)
+	}
+
+	transport := &kafka.Transport{}
+	dialer := &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true}
+
+	if cfg.KafkaTLS {
+		transport.TLS = &tls.Config{}
+		dialer.TLS = &tls.Config{}
+	}
+
+	if cfg.KafkaSASLUser != "" {
+		mechanism := plain.Mechanism{Username: cfg.KafkaSASLUser, Password: cfg.KafkaSASLPassword}
+		transport.SASL = mechanism
+		dialer.SASLMechanism = mechanism
+	}
+
+	return &remoteBroker{
+		brokers:   cfg.KafkaBrokers,
+		acks:      required,
+		transport: transport,
+		dialer:    dialer,
+	}, nil
+}
+
+func (b *remoteBroker) Writer(topic string) kafkaWriter {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(b.brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           b.acks,
+		Async:                  true,
+		BatchSize:              1,
+		AllowAutoTopicCreation: true,
+		Transport:              b.transport,
+	}
+}
+
+func (b *remoteBroker) DeleteTopic(topic string) {
+	conn, err := b.dialer.Dial("tcp", b.brokers[0])
+
+	if err != nil {
+		log.Println("failed to dial to remove topic " + topic)
+		return
+	}
+
+	defer conn.Close()
+
+	conn.DeleteTopics(topic)
+}
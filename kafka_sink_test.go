@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestKafkaSinkKeepsRoomsInOrder publishes interleaved events for several rooms
+// and checks that each room's events land on one partition, in sequence order
+func TestKafkaSinkKeepsRoomsInOrder(t *testing.T) {
+	const events = 20
+
+	rooms := []string{"roomA", "roomB", "roomC", "roomD", "roomE"}
+
+	for _, perRoom := range []bool{false, true} {
+		broker := newMemoryBroker(MEMORY_BROKER_PARTITIONS)
+		sink := newKafkaSink(Config{KafkaTopic: "events", KafkaTopicPerRoom: perRoom, KafkaTopicPrefix: "room-"}, broker)
+
+		for _, room := range rooms {
+			sink.OpenRoom(room)
+		}
+
+		for sequence := int64(1); sequence <= events; sequence++ {
+			for _, room := range rooms {
+				sink.Publish(GameEvent{Version: EVENT_SCHEMA_VERSION, Type: EVENT_TURN_SKIPPED, Room: room, Sequence: sequence})
+			}
+		}
+
+		for _, room := range rooms {
+			topic := "events"
+			if perRoom {
+				topic = sink.topic(room)
+			}
+
+			partitions := 0
+			for partition := 0; partition < MEMORY_BROKER_PARTITIONS; partition++ {
+				var sequences []int64
+
+				for _, msg := range broker.Messages(topic, partition) {
+					var event GameEvent
+					if err := json.Unmarshal(msg.Value, &event); err != nil {
+						t.Fatal(err)
+					}
+
+					if string(msg.Key) != event.Room {
+						t.Fatalf("message for %s is keyed %q", event.Room, msg.Key)
+					}
+
+					if event.Room == room {
+						sequences = append(sequences, event.Sequence)
+					}
+				}
+
+				if len(sequences) == 0 {
+					continue
+				}
+
+				partitions++
+
+				if len(sequences) != events {
+					t.Errorf("per room %v: %s has %d of its %d events on partition %d", perRoom, room, len(sequences), events, partition)
+				}
+
+				for i, sequence := range sequences {
+					if sequence != int64(i+1) {
+						t.Errorf("per room %v: %s events out of order on partition %d: %v", perRoom, room, partition, sequences)
+						break
+					}
+				}
+			}
+
+			if partitions != 1 {
+				t.Errorf("per room %v: %s events are spread over %d partitions", perRoom, room, partitions)
+			}
+		}
+
+		if err := sink.Close(); err != nil {
+			t.Fatalf("closing sink: %s", err.Error())
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 
 	"math/rand"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
@@ -14,12 +17,13 @@ import (
 var (
 	clientRooms = make(map[string]*Room)
 	clientRoomsLock sync.RWMutex
-
-	randomRooms = make([]*Room, 0, 10)
-	randomRoomsLock sync.Mutex
 )
 
-const NUM = 4
+// bounds on how many players can share a room
+const (
+	MIN_PLAYERS = 2
+	MAX_PLAYERS = 6
+)
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize: 1024,
@@ -30,6 +34,18 @@ var upgrader = websocket.Upgrader{
 }
 
 func handleConnections(w http.ResponseWriter, r *http.Request) {
+	// browsers can't set headers on websockets, so the session token comes in the query
+	var account *Account
+	if token := r.URL.Query().Get("token"); token != "" {
+		found, err := accountStore.BySession(token)
+		if err != nil {
+			http.Error(w, "invalid session token", http.StatusUnauthorized)
+			return
+		}
+
+		account = found
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 
 	if err != nil {
@@ -42,14 +58,68 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 		RoomName: "", 
 		UniqueNumber: rand.Int(), 
 		Number: -1, 
+		Account: account,
 	}
 
 	wsClient.HandleClient()
 }
 
 func main() {
+	// boards use their own seeded sources, this only covers room codes and client ids
+	rand.Seed(time.Now().UnixNano())
+
+	cfg := loadConfig()
+
+	sink, err := newEventSink(cfg)
+	if err != nil {
+		log.Fatalf("failed to set up event sink: %s\n", err.Error())
+	}
+
+	store, err := newReplayStore(cfg)
+	if err != nil {
+		log.Fatalf("failed to set up replay store: %s\n", err.Error())
+	}
+
+	replayStore = store
+
+	accounts, err := newAccountStore(cfg)
+	if err != nil {
+		log.Fatalf("failed to load accounts: %s\n", err.Error())
+	}
+
+	accountStore = accounts
+
+	stats, err := newStatsStore(cfg)
+	if err != nil {
+		log.Fatalf("failed to load stats: %s\n", err.Error())
+	}
+
+	statsStore = stats
+
+	ratings, err := newRatingStore(cfg)
+	if err != nil {
+		log.Fatalf("failed to load ratings: %s\n", err.Error())
+	}
+
+	ratingStore = ratings
+
+	// every game is recorded for replay on top of whatever sink was configured
+	eventSink = multiSink{sink, newReplayRecorder(replayStore)}
+	defer eventSink.Close()
+
+	loadDictionaries()
+
+	matchmaker = newMatchmaker(cfg.MatchmakingTimeout)
+	go matchmaker.Run(context.Background())
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", handleConnections)
+	mux.HandleFunc("/games/", handleGames)
+	mux.HandleFunc("/auth/", handleAuth)
+	mux.HandleFunc("/leaderboard", handleLeaderboard)
+	mux.HandleFunc("/matchmaking", handleMatchmaking)
+	mux.HandleFunc("/rooms/", handleRooms)
+	mux.HandleFunc("/players/", handlePlayers)
 	handler := cors.Default().Handler(mux)
 
 	server := &http.Server{
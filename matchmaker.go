@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A queued player is matched with others whose rating is within a window that
+// starts at MATCH_INITIAL_WINDOW and widens the longer they wait, until after
+// MATCH_FALLBACK anyone who asked for the same settings will do
+const (
+	MATCH_INITIAL_WINDOW = 100.0
+	MATCH_WINDOW_GROWTH  = 50.0 // added to the window every MATCH_WIDEN_INTERVAL
+	MATCH_WIDEN_INTERVAL = 5 * time.Second
+	MATCH_FALLBACK       = 30 * time.Second
+	MATCH_TICK           = time.Second // how often waiting players are matched again
+)
+
+// queued players hear how they are doing every QUEUE_STATUS_INTERVAL and are
+// taken out of the queue after DEFAULT_MATCHMAKING_TIMEOUT unless configured otherwise
+const (
+	QUEUE_STATUS_INTERVAL       = 5 * time.Second
+	DEFAULT_MATCHMAKING_TIMEOUT = 2 * time.Minute
+)
+
+// Ticket is one client waiting in the random game queue
+type Ticket struct {
+	Client   *WSClient
+	Settings GameSettings
+	Rating   float64
+	Joined   time.Time
+	Notified time.Time // when the last queueStatus was sent
+}
+
+// window returns the rating difference the ticket accepts after waiting until now
+func (t *Ticket) window(now time.Time) float64 {
+	return MATCH_INITIAL_WINDOW + MATCH_WINDOW_GROWTH*float64(now.Sub(t.Joined)/MATCH_WIDEN_INTERVAL)
+}
+
+// patient reports whether the ticket has waited long enough to take anyone
+func (t *Ticket) patient(now time.Time) bool {
+	return now.Sub(t.Joined) >= MATCH_FALLBACK
+}
+
+// MatchmakerMetrics summarizes how well the queue has been matching players
+type MatchmakerMetrics struct {
+	Queued              int     `json:"queued"`
+	Matches             int     `json:"matches"`
+	FallbackMatches     int     `json:"fallbackMatches"` // matches only made by falling back to anyone
+	AverageWaitSeconds  float64 `json:"averageWaitSeconds"`
+	MaxWaitSeconds      float64 `json:"maxWaitSeconds"`
+	AverageRatingSpread float64 `json:"averageRatingSpread"` // highest minus lowest rating in a match
+}
+
+// Matchmaker holds the players waiting for a random game and groups them into
+// rooms by settings and rating
+type Matchmaker struct {
+	tickets []*Ticket     // oldest first
+	timeout time.Duration // how long a ticket may wait before it times out, 0 for forever
+	lock    sync.Mutex
+
+	recentWait time.Duration // moving average of the wait of matched players
+
+	matches        int
+	fallbacks      int
+	matchedPlayers int
+	totalWait      time.Duration
+	maxWait        time.Duration
+	totalSpread    float64
+}
+
+// matchmaker is replaced in main once the timeout is configured
+var matchmaker = newMatchmaker(DEFAULT_MATCHMAKING_TIMEOUT)
+
+func newMatchmaker(timeout time.Duration) *Matchmaker {
+	return &Matchmaker{timeout: timeout}
+}
+
+// Enqueue puts c in the queue, replacing any ticket it already had, and starts
+// any games that can be made right away
+func (m *Matchmaker) Enqueue(c *WSClient, settings GameSettings, rating float64) {
+	c.WriteJSON(StatusMessage{Type: "randomWaiting"})
+
+	now := time.Now()
+
+	m.lock.Lock()
+	m.remove(c)
+	m.tickets = append(m.tickets, &Ticket{Client: c, Settings: settings, Rating: rating, Joined: now, Notified: now})
+	groups := m.match(now)
+	m.lock.Unlock()
+
+	for _, group := range groups {
+		startMatch(group)
+	}
+}
+
+// Remove takes c out of the queue, reporting whether it was in it
+func (m *Matchmaker) Remove(c *WSClient) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.remove(c)
+}
+
+// remove is Remove for callers already holding m.lock
+func (m *Matchmaker) remove(c *WSClient) bool {
+	for i, ticket := range m.tickets {
+		if ticket.Client == c {
+			m.tickets = append(m.tickets[:i], m.tickets[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run matches waiting players every MATCH_TICK, so windows widen even when
+// nobody new joins the queue. Players who waited too long are timed out, and
+// everyone else is sent a queueStatus every QUEUE_STATUS_INTERVAL
+func (m *Matchmaker) Run(ctx context.Context) {
+	ticker := time.NewTicker(MATCH_TICK)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.lock.Lock()
+			groups := m.match(now)
+			expired := m.expire(now)
+			statuses := m.statuses(now)
+			m.lock.Unlock()
+
+			for _, group := range groups {
+				startMatch(group)
+			}
+
+			for _, ticket := range expired {
+				ticket.Client.WriteJSON(StatusMessage{Type: "matchmakingTimeout"})
+				fmt.Printf("%d timed out in the random game queue\n", ticket.Client.UniqueNumber)
+			}
+
+			// a connection that can no longer be written to has been abandoned
+			for c, status := range statuses {
+				if err := c.WriteJSON(status); err != nil && m.Remove(c) {
+					fmt.Printf("%d reaped from the random game queue: %s\n", c.UniqueNumber, err.Error())
+				}
+			}
+		}
+	}
+}
+
+// expire takes every ticket that has waited longer than m.timeout out of the
+// queue and returns them. Caller must hold m.lock
+func (m *Matchmaker) expire(now time.Time) []*Ticket {
+	expired := []*Ticket{}
+	if m.timeout <= 0 {
+		return expired
+	}
+
+	remaining := m.tickets[:0]
+	for _, ticket := range m.tickets {
+		if now.Sub(ticket.Joined) >= m.timeout {
+			expired = append(expired, ticket)
+		} else {
+			remaining = append(remaining, ticket)
+		}
+	}
+
+	m.tickets = remaining
+	return expired
+}
+
+// statuses builds a queueStatus for every ticket due one. Position only counts
+// tickets with the same settings, since nobody else can take their spot.
+// Caller must hold m.lock
+func (m *Matchmaker) statuses(now time.Time) map[*WSClient]QueueStatusMessage {
+	statuses := make(map[*WSClient]QueueStatusMessage)
+
+	// until anyone has been matched, guess that players wait for the fallback
+	perRoom := m.recentWait
+	if m.matches == 0 {
+		perRoom = MATCH_FALLBACK
+	}
+
+	for i, ticket := range m.tickets {
+		if now.Sub(ticket.Notified) < QUEUE_STATUS_INTERVAL {
+			continue
+		}
+
+		position := 1
+		for _, ahead := range m.tickets[:i] {
+			if sameSettings(ahead.Settings, ticket.Settings) {
+				position++
+			}
+		}
+
+		ticket.Notified = now
+		waited := now.Sub(ticket.Joined)
+
+		// everyone ahead is matched a room at a time, each taking about as long
+		// as recent players have waited
+		rooms := (position-1)/ticket.Settings.Capacity + 1
+		estimate := time.Duration(rooms)*perRoom - waited
+		if estimate < 0 {
+			estimate = 0
+		}
+
+		if m.timeout > 0 && waited+estimate > m.timeout {
+			estimate = m.timeout - waited
+		}
+
+		statuses[ticket.Client] = QueueStatusMessage{
+			Type:                 "queueStatus",
+			Position:             position,
+			WaitedSeconds:        int(waited.Seconds()),
+			EstimatedWaitSeconds: int(estimate.Seconds()),
+		}
+	}
+
+	return statuses
+}
+
+// sameSettings reports whether two queued players could play each other
+func sameSettings(a GameSettings, b GameSettings) bool {
+	return a.Dictionary == b.Dictionary && a.Board == b.Board && a.Capacity == b.Capacity && a.Mode == b.Mode && a.Timer == b.Timer
+}
+
+// match takes every full group it can make out of the queue. The oldest ticket
+// is matched first, with the closest ratings its window allows. Caller must
+// hold m.lock
+func (m *Matchmaker) match(now time.Time) [][]*Ticket {
+	groups := [][]*Ticket{}
+
+	for i := 0; i < len(m.tickets); i++ {
+		anchor := m.tickets[i]
+		candidates := []*Ticket{}
+
+		// older tickets already failed to match this one, so only look at newer ones
+		for _, other := range m.tickets[i+1:] {
+			if !sameSettings(other.Settings, anchor.Settings) {
+				continue
+			}
+
+			diff := math.Abs(other.Rating - anchor.Rating)
+			if diff <= math.Max(anchor.window(now), other.window(now)) || anchor.patient(now) || other.patient(now) {
+				candidates = append(candidates, other)
+			}
+		}
+
+		need := anchor.Settings.Capacity - 1
+		if len(candidates) < need {
+			continue
+		}
+
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return math.Abs(candidates[a].Rating-anchor.Rating) < math.Abs(candidates[b].Rating-anchor.Rating)
+		})
+
+		group := append([]*Ticket{anchor}, candidates[:need]...)
+		m.record(group, now)
+
+		matched := make(map[*Ticket]bool)
+		for _, ticket := range group {
+			matched[ticket] = true
+		}
+
+		remaining := m.tickets[:0]
+		for _, ticket := range m.tickets {
+			if !matched[ticket] {
+				remaining = append(remaining, ticket)
+			}
+		}
+
+		m.tickets = remaining
+		groups = append(groups, group)
+
+		// every ticket before i is still in place, so look at the one that moved into i
+		i--
+	}
+
+	return groups
+}
+
+// record adds a match to the metrics. Caller must hold m.lock
+func (m *Matchmaker) record(group []*Ticket, now time.Time) {
+	lowest, highest := group[0].Rating, group[0].Rating
+	fallback := false
+
+	for _, ticket := range group {
+		wait := now.Sub(ticket.Joined)
+
+		m.matchedPlayers++
+		m.totalWait += wait
+		m.recentWait = (m.recentWait*3 + wait) / 4
+		if wait > m.maxWait {
+			m.maxWait = wait
+		}
+
+		lowest = math.Min(lowest, ticket.Rating)
+		highest = math.Max(highest, ticket.Rating)
+
+		diff := math.Abs(ticket.Rating - group[0].Rating)
+		if diff > math.Max(group[0].window(now), ticket.window(now)) {
+			fallback = true
+		}
+	}
+
+	m.matches++
+	m.totalSpread += highest - lowest
+
+	if fallback {
+		m.fallbacks++
+	}
+}
+
+func (m *Matchmaker) Metrics() MatchmakerMetrics {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	metrics := MatchmakerMetrics{
+		Queued:          len(m.tickets),
+		Matches:         m.matches,
+		FallbackMatches: m.fallbacks,
+		MaxWaitSeconds:  m.maxWait.Seconds(),
+	}
+
+	if m.matchedPlayers > 0 {
+		metrics.AverageWaitSeconds = m.totalWait.Seconds() / float64(m.matchedPlayers)
+	}
+
+	if m.matches > 0 {
+		metrics.AverageRatingSpread = m.totalSpread / float64(m.matches)
+	}
+
+	return metrics
+}
+
+// startMatch creates a room for a matched group and seats everyone in it, in
+// the order they joined the queue
+func startMatch(group []*Ticket) {
+	roomName := makeID(15)
+
+	initGame(roomName, group[0].Settings)
+
+	clientRoomsLock.RLock()
+	room, exists := clientRooms[roomName]
+	clientRoomsLock.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.RoomLock.Lock()
+
+	for i, ticket := range group {
+		c := ticket.Client
+		c.RoomName = roomName
+		c.Number = i + 1
+
+		seat := c.newSeat(c.Number)
+		room.Seats = append(room.Seats, seat)
+
+		c.WriteJSON(GameCodeMessage{Type: "matchFound", RoomName: roomName})
+		c.WriteJSON(InitMessage{
+			Type:        "init",
+			Number:      c.Number,
+			Capacity:    room.Capacity,
+			ResumeToken: seat.Token,
+			DisplayName: seat.DisplayName,
+		})
+
+		publishEvent(room, EVENT_PLAYER_JOINED, c.Number, seatInfo(seat, c.Number))
+	}
+
+	room.RoomLock.Unlock()
+
+	fmt.Printf("matched %d players into room %s\n", len(group), roomName)
+
+	startGame(room)
+}
+
+// leaveQueue takes c out of the random game queue if it is waiting there
+func (c *WSClient) leaveQueue() {
+	matchmaker.Remove(c)
+}
+
+func handleCancelRandom(c *WSClient, msg CancelRandomMessage) error {
+	if !matchmaker.Remove(c) {
+		return &ProtocolError{Code: "notQueued", Message: "not waiting for a random game"}
+	}
+
+	c.WriteJSON(StatusMessage{Type: "randomCancelled"})
+
+	return nil
+}
+
+// handleMatchmaking serves GET /matchmaking with the queue's metrics
+func handleMatchmaking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeHTTPError(w, http.StatusMethodNotAllowed, "methodNotAllowed", "use GET")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, matchmaker.Metrics())
+}
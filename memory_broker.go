@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// partitions per topic on the in-memory broker
+const MEMORY_BROKER_PARTITIONS = 4
+
+// memoryBroker stands in for a Kafka cluster in local development and tests.
+// Messages are partitioned by key with the same balancer the real writer uses,
+// so per-room ordering behaves like it does against a real cluster
+type memoryBroker struct {
+	partitions int
+	topics     map[string][][]kafka.Message // topic -> partition -> messages
+	lock       sync.Mutex
+}
+
+func newMemoryBroker(partitions int) *memoryBroker {
+	return &memoryBroker{
+		partitions: partitions,
+		topics:     make(map[string][][]kafka.Message),
+	}
+}
+
+func (b *memoryBroker) Writer(topic string) kafkaWriter {
+	return &memoryWriter{broker: b, topic: topic}
+}
+
+func (b *memoryBroker) DeleteTopic(topic string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.topics, topic)
+}
+
+// Messages returns a copy of everything written to one partition of a topic
+func (b *memoryBroker) Messages(topic string, partition int) []kafka.Message {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	partitions, exists := b.topics[topic]
+	if !exists || partition < 0 || partition >= len(partitions) {
+		return nil
+	}
+
+	return append([]kafka.Message(nil), partitions[partition]...)
+}
+
+func (b *memoryBroker) write(topic string, msgs []kafka.Message) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	partitions, exists := b.topics[topic]
+	if !exists {
+		partitions = make([][]kafka.Message, b.partitions)
+		b.topics[topic] = partitions
+	}
+
+	ids := make([]int, b.partitions)
+	for i := range ids {
+		ids[i] = i
+	}
+
+	balancer := &kafka.Hash{}
+
+	for _, msg := range msgs {
+		partition := balancer.Balance(msg, ids...)
+
+		msg.Topic = topic
+		msg.Partition = partition
+		msg.Offset = int64(len(partitions[partition]))
+
+		partitions[partition] = append(partitions[partition], msg)
+	}
+}
+
+type memoryWriter struct {
+	broker *memoryBroker
+	topic  string
+}
+
+func (w *memoryWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.broker.write(w.topic, msgs)
+	return nil
+}
+
+func (w *memoryWriter) Close() error {
+	return nil
+}
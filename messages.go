@@ -0,0 +1,192 @@
+package main
+
+import "encoding/json"
+
+// Outbound messages. Every message the server sends is one of these structs,
+// with Type naming the message for the client
+
+// StatusMessage carries no data beyond its type, e.g. "unknownGame" or "disconnected"
+type StatusMessage struct {
+	Type string `json:"type"`
+}
+
+// ErrorMessage is the reply to a message the server could not handle
+type ErrorMessage struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	For     string `json:"for,omitempty"` // type of the message that caused the error, if known
+}
+
+// GameCodeMessage tells a player the code of their room ("gameCode" for its creator,
+// "matchFound" for players matched from the random game queue)
+type GameCodeMessage struct {
+	Type     string   `json:"type"`
+	RoomName string   `json:"roomName"`
+	Invites  []string `json:"invites,omitempty"` // single-use invite tokens of a private room
+}
+
+type InitMessage struct {
+	Type        string `json:"type"`
+	Number      int    `json:"number"`
+	Capacity    int    `json:"capacity"`
+	ResumeToken string `json:"resumeToken"`
+	DisplayName string `json:"displayName"`
+}
+
+type PlayerJoinedMessage struct {
+	Type     string `json:"type"`
+	Players  int    `json:"players"`
+	Capacity int    `json:"capacity"`
+}
+
+type StartMessage struct {
+	Type      string          `json:"type"`
+	Countdown [2]int          `json:"countdown"`
+	Seed      int64           `json:"seed"`
+	Players   []PlayerInfo    `json:"players"`
+	GameInfo  json.RawMessage `json:"gameInfo"` // the room, see gameInfo
+}
+
+type SwitchMessage struct {
+	Type   string    `json:"type"`
+	Player int       `json:"player"`
+	Word   string    `json:"word"`
+	Path   []Tile    `json:"path"`
+	Scores []float64 `json:"scores"`
+}
+
+type TickMessage struct {
+	Type      string `json:"type"`
+	Player    int    `json:"player"`
+	Countdown [2]int `json:"countdown"`
+}
+
+type EndGameMessage struct {
+	Type    string         `json:"type"`
+	Scores  []float64      `json:"scores"`
+	Players []PlayerInfo   `json:"players"`
+	Words   [][]WordResult `json:"words,omitempty"`  // only for simultaneous games
+	Series  *SeriesScore   `json:"series,omitempty"` // only for series and rematches
+}
+
+// PlayerStatusMessage tells the other players someone dropped or came back
+type PlayerStatusMessage struct {
+	Type   string `json:"type"`
+	Player int    `json:"player"`
+	Grace  int    `json:"grace"` // seconds a dropped player has to resume
+}
+
+type WordAcceptedMessage struct {
+	Type   string    `json:"type"`
+	Word   string    `json:"word"`
+	Points int       `json:"points,omitempty"`
+	Scores []float64 `json:"scores,omitempty"`
+	Found  int       `json:"found,omitempty"` // words found so far in a simultaneous game
+}
+
+type WordRejectedMessage struct {
+	Type      string     `json:"type"`
+	Word      string     `json:"word"`
+	Reason    string     `json:"reason"`
+	PathError *PathError `json:"pathError,omitempty"`
+}
+
+type ResumedMessage struct {
+	Type          string          `json:"type"`
+	Number        int             `json:"number"`
+	CurrentPlayer int             `json:"currentPlayer"`
+	Countdown     [2]int          `json:"countdown"`
+	Words         []string        `json:"words,omitempty"` // the player's own words so far in a simultaneous game
+	GameInfo      json.RawMessage `json:"gameInfo"`        // the room, see gameInfo
+}
+
+type SpectatingMessage struct {
+	Type          string          `json:"type"`
+	Started       bool            `json:"started"`
+	CurrentPlayer int             `json:"currentPlayer"`
+	Countdown     [2]int          `json:"countdown"`
+	Scores        []float64       `json:"scores"`
+	GameInfo      json.RawMessage `json:"gameInfo"` // the room, see gameInfo
+}
+
+type SpectatorsMessage struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+	Max   int    `json:"max"`
+}
+
+// WelcomeMessage answers hello with what the server supports and what was enabled
+type WelcomeMessage struct {
+	Type         string         `json:"type"`
+	MinVersion   int            `json:"minVersion"`
+	MaxVersion   int            `json:"maxVersion"`
+	Version      int            `json:"version"` // version the rest of the connection uses
+	Features     []string       `json:"features"`
+	Dictionaries []string       `json:"dictionaries"`
+	Boards       map[string]int `json:"boards"` // board name -> side length
+	Modes        []string       `json:"modes"`
+	MinPlayers   int            `json:"minPlayers"`
+	MaxPlayers   int            `json:"maxPlayers"`
+}
+
+type ReplayEventMessage struct {
+	Type   string    `json:"type"`
+	GameID string    `json:"gameId"`
+	Event  GameEvent `json:"event"`
+}
+
+type ReplayEndMessage struct {
+	Type   string `json:"type"`
+	GameID string `json:"gameId"`
+}
+
+// ReplayResponse is the body of GET /games/{id}/replay
+type ReplayResponse struct {
+	ID     string      `json:"id"`
+	Events []GameEvent `json:"events"`
+}
+
+type AuthenticatedMessage struct {
+	Type   string     `json:"type"`
+	Player PlayerInfo `json:"player"`
+}
+
+// QueueStatusMessage is sent periodically to players in the random game queue
+type QueueStatusMessage struct {
+	Type                 string `json:"type"`
+	Position             int    `json:"position"` // 1 is next in line among players with the same settings
+	WaitedSeconds        int    `json:"waitedSeconds"`
+	EstimatedWaitSeconds int    `json:"estimatedWaitSeconds"` // rough time left until a match
+}
+
+// RematchRequestedMessage tells the players of a finished game that one of them
+// wants to play again, and with what settings
+type RematchRequestedMessage struct {
+	Type       string `json:"type"`
+	Player     int    `json:"player"` // number the player had in the finished game
+	SwapFirst  bool   `json:"swapFirst"`
+	Dictionary string `json:"dictionary"`
+	Board      string `json:"board"`
+	Mode       string `json:"mode"`
+}
+
+type RematchAcceptedMessage struct {
+	Type   string `json:"type"`
+	Player int    `json:"player"`
+}
+
+// RematchStartedMessage moves a player into the room of a rematch
+// ("rematchStarted") or of the next board of a series ("nextBoard"), followed by init
+type RematchStartedMessage struct {
+	Type     string       `json:"type"`
+	RoomName string       `json:"roomName"`
+	Series   *SeriesScore `json:"series"` // standing before this game
+}
+
+// SeriesUpdateMessage follows the endgame of every board in a best-of series
+type SeriesUpdateMessage struct {
+	Type        string       `json:"type"`
+	Series      *SeriesScore `json:"series"`
+	NextBoardIn int          `json:"nextBoardIn,omitempty"` // seconds until the next board starts
+}
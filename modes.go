@@ -0,0 +1,51 @@
+package main
+
+// game modes a room can be played in
+const (
+	MODE_TURNS        = "turns"        // players alternate, one word per turn
+	MODE_SIMULTANEOUS = "simultaneous" // classic Boggle, everyone searches at once until time runs out
+)
+
+var MODES = map[string]bool{
+	MODE_TURNS:        true,
+	MODE_SIMULTANEOUS: true,
+}
+
+// WordResult is how a single word counted towards a player's final score
+type WordResult struct {
+	Word   string `json:"word"`
+	Points int    `json:"points"`
+	Shared bool   `json:"shared"` // found by more than one player, so worth nothing
+}
+
+// scoreSimultaneous applies classic rules to a simultaneous game: words found
+// by more than one player score zero for all of them. It sets every seat's
+// score and returns the per-word breakdown in seat order.
+// Caller must hold room.RoomLock
+func scoreSimultaneous(room *Room) [][]WordResult {
+	finders := make(map[string]int)
+	for _, seat := range room.Seats {
+		for _, word := range seat.Words {
+			finders[word]++
+		}
+	}
+
+	breakdown := make([][]WordResult, len(room.Seats))
+
+	for i, seat := range room.Seats {
+		seat.Score = 0
+		breakdown[i] = []WordResult{}
+
+		for _, word := range seat.Words {
+			result := WordResult{Word: word, Shared: finders[word] > 1}
+			if !result.Shared {
+				result.Points = scoreWord(word)
+			}
+
+			seat.Score += float64(result.Points)
+			breakdown[i] = append(breakdown[i], result)
+		}
+	}
+
+	return breakdown
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScoreSimultaneous(t *testing.T) {
+	tests := []struct {
+		name      string
+		words     [][]string // words found by each seat
+		scores    []float64
+		breakdown [][]WordResult
+	}{
+		{
+			name:      "no words",
+			words:     [][]string{nil, nil},
+			scores:    []float64{0, 0},
+			breakdown: [][]WordResult{{}, {}},
+		},
+		{
+			name:   "nothing shared",
+			words:  [][]string{{"CAT", "STONE"}, {"DOGS"}},
+			scores: []float64{3, 1},
+			breakdown: [][]WordResult{
+				{{Word: "CAT", Points: 1}, {Word: "STONE", Points: 2}},
+				{{Word: "DOGS", Points: 1}},
+			},
+		},
+		{
+			name:   "shared words score nothing for both",
+			words:  [][]string{{"CAT", "STONES"}, {"CAT", "ROAST"}},
+			scores: []float64{3, 2},
+			breakdown: [][]WordResult{
+				{{Word: "CAT", Shared: true}, {Word: "STONES", Points: 3}},
+				{{Word: "CAT", Shared: true}, {Word: "ROAST", Points: 2}},
+			},
+		},
+		{
+			name:   "shared by two of three players",
+			words:  [][]string{{"QUEST"}, {"QUEST", "TREASURE"}, {"TREASURES"}},
+			scores: []float64{0, 11, 11},
+			breakdown: [][]WordResult{
+				{{Word: "QUEST", Shared: true}},
+				{{Word: "QUEST", Shared: true}, {Word: "TREASURE", Points: 11}},
+				{{Word: "TREASURES", Points: 11}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		room := &Room{}
+		for _, words := range test.words {
+			// scores from earlier in the game are replaced
+			room.Seats = append(room.Seats, &Seat{Words: words, Score: 99})
+		}
+
+		breakdown := scoreSimultaneous(room)
+
+		if !reflect.DeepEqual(breakdown, test.breakdown) {
+			t.Errorf("%s: got breakdown %v, want %v", test.name, breakdown, test.breakdown)
+		}
+
+		if got := scores(room); !reflect.DeepEqual(got, test.scores) {
+			t.Errorf("%s: got scores %v, want %v", test.name, got, test.scores)
+		}
+	}
+}
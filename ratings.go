@@ -0,0 +1,177 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sync"
+)
+
+// Elo parameters. New players move faster until their rating has settled
+const (
+	DEFAULT_RATING    = 1500.0
+	PROVISIONAL_GAMES = 20 // games played before a rating stops being provisional
+	PROVISIONAL_K     = 40.0
+	RATING_K          = 20.0
+)
+
+// Rating is a player's Elo rating and the number of rated games behind it
+type Rating struct {
+	Rating float64 `json:"rating"`
+	Games  int     `json:"games"`
+}
+
+// RatingStore keeps the rating of every signed-in player
+type RatingStore interface {
+	// Get returns the player's rating, or a fresh DEFAULT_RATING if they have none
+	Get(playerID string) (Rating, error)
+	Update(ratings map[string]Rating) error
+}
+
+// ratingStore is chosen by newRatingStore at startup
+var ratingStore RatingStore = newJSONRatingStore("")
+
+func newRatingStore(cfg Config) (RatingStore, error) {
+	store := newJSONRatingStore(cfg.RatingFile)
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// jsonRatingStore keeps ratings in memory and, when given a path, rewrites them
+// to a JSON file after every rated game
+type jsonRatingStore struct {
+	path    string
+	ratings map[string]Rating // player id -> rating
+	lock    sync.RWMutex
+}
+
+func newJSONRatingStore(path string) *jsonRatingStore {
+	return &jsonRatingStore{path: path, ratings: make(map[string]Rating)}
+}
+
+func (s *jsonRatingStore) load() error {
+	return loadJSONFile(s.path, &s.ratings)
+}
+
+func (s *jsonRatingStore) Get(playerID string) (Rating, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	rating, exists := s.ratings[playerID]
+	if !exists {
+		return Rating{Rating: DEFAULT_RATING}, nil
+	}
+
+	return rating, nil
+}
+
+func (s *jsonRatingStore) Update(ratings map[string]Rating) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for id, rating := range ratings {
+		s.ratings[id] = rating
+	}
+
+	return saveJSONFile(s.path, s.ratings, 0644)
+}
+
+// expectedScore is the chance Elo gives a player rated a of beating one rated b
+func expectedScore(a float64, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// updateRatings rates a finished game. Games with more than two players are
+// scored as a head to head between every pair of signed-in players, with the
+// K factor split between a player's opponents
+func updateRatings(room *Room) {
+	rateGame(room, nil)
+}
+
+// forfeitRatings rates a game that was aborted because player did not come back
+// in time as a loss for them against everyone else
+func forfeitRatings(room *Room, player int) {
+	if player < 1 || player > len(room.Seats) || room.Seats[player-1].PlayerID == "" {
+		return
+	}
+
+	rateGame(room, room.Seats[player-1])
+}
+
+// rateGame updates the rating of every signed-in player in the room. Without a
+// forfeiting seat players are compared by score, otherwise only against the
+// forfeiting player, who loses to everyone
+func rateGame(room *Room, forfeit *Seat) {
+	seats := []*Seat{}
+	seen := make(map[string]bool)
+
+	for _, seat := range room.Seats {
+		if seat.PlayerID != "" && !seen[seat.PlayerID] {
+			seen[seat.PlayerID] = true
+			seats = append(seats, seat)
+		}
+	}
+
+	if len(seats) < 2 {
+		return
+	}
+
+	current := make([]Rating, len(seats))
+	for i, seat := range seats {
+		rating, err := ratingStore.Get(seat.PlayerID)
+		if err != nil {
+			log.Printf("failed to load rating of %s: %s\n", seat.PlayerID, err.Error())
+			return
+		}
+
+		current[i] = rating
+	}
+
+	updated := make(map[string]Rating)
+
+	for i, seat := range seats {
+		k := RATING_K
+		if current[i].Games < PROVISIONAL_GAMES {
+			k = PROVISIONAL_K
+		}
+
+		opponents := len(seats) - 1
+		if forfeit != nil && seat.PlayerID != forfeit.PlayerID {
+			opponents = 1
+		}
+
+		k /= float64(opponents)
+
+		delta := 0.0
+		for j, other := range seats {
+			if i == j {
+				continue
+			}
+
+			actual := 0.5
+			if forfeit != nil {
+				if seat.PlayerID == forfeit.PlayerID {
+					actual = 0
+				} else if other.PlayerID == forfeit.PlayerID {
+					actual = 1
+				} else {
+					continue
+				}
+			} else if seat.Score > other.Score {
+				actual = 1
+			} else if seat.Score < other.Score {
+				actual = 0
+			}
+
+			delta += k * (actual - expectedScore(current[i].Rating, current[j].Rating))
+		}
+
+		updated[seat.PlayerID] = Rating{Rating: current[i].Rating + delta, Games: current[i].Games + 1}
+	}
+
+	if err := ratingStore.Update(updated); err != nil {
+		log.Printf("failed to update ratings for %s: %s\n", room.RoomName, err.Error())
+	}
+}
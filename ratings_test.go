@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRateGame(t *testing.T) {
+	settled := func(rating float64) Rating {
+		return Rating{Rating: rating, Games: PROVISIONAL_GAMES}
+	}
+
+	tests := []struct {
+		name    string
+		seats   []*Seat
+		before  map[string]Rating
+		forfeit int // seat number of the player who did not come back, 0 for a finished game
+		after   map[string]Rating
+	}{
+		{
+			name:   "new players, higher score wins",
+			seats:  []*Seat{{PlayerID: "a", Score: 5}, {PlayerID: "b", Score: 2}},
+			before: map[string]Rating{},
+			after:  map[string]Rating{"a": {Rating: 1520, Games: 1}, "b": {Rating: 1480, Games: 1}},
+		},
+		{
+			name:   "draw between equals",
+			seats:  []*Seat{{PlayerID: "a", Score: 3}, {PlayerID: "b", Score: 3}},
+			before: map[string]Rating{"a": settled(1500), "b": settled(1500)},
+			after:  map[string]Rating{"a": {Rating: 1500, Games: 21}, "b": {Rating: 1500, Games: 21}},
+		},
+		{
+			name:   "upset moves settled ratings by RATING_K",
+			seats:  []*Seat{{PlayerID: "a", Score: 1}, {PlayerID: "b", Score: 4}},
+			before: map[string]Rating{"a": settled(1700), "b": settled(1500)},
+			after:  map[string]Rating{"a": {Rating: 1684.81, Games: 21}, "b": {Rating: 1515.19, Games: 21}},
+		},
+		{
+			name:   "anonymous players are not rated",
+			seats:  []*Seat{{PlayerID: "a", Score: 5}, {Score: 2}},
+			before: map[string]Rating{},
+			after:  map[string]Rating{},
+		},
+		{
+			name:   "one account in two seats is rated once",
+			seats:  []*Seat{{PlayerID: "a", Score: 5}, {PlayerID: "a", Score: 2}},
+			before: map[string]Rating{},
+			after:  map[string]Rating{},
+		},
+		{
+			name:    "forfeit loses despite the higher score",
+			seats:   []*Seat{{PlayerID: "a", Score: 9}, {PlayerID: "b", Score: 0}},
+			before:  map[string]Rating{},
+			forfeit: 1,
+			after:   map[string]Rating{"a": {Rating: 1480, Games: 1}, "b": {Rating: 1520, Games: 1}},
+		},
+		{
+			name:    "forfeit only rates the others against the forfeiting player",
+			seats:   []*Seat{{PlayerID: "a", Score: 1}, {PlayerID: "b", Score: 9}, {PlayerID: "c", Score: 0}},
+			before:  map[string]Rating{},
+			forfeit: 1,
+			after:   map[string]Rating{"a": {Rating: 1480, Games: 1}, "b": {Rating: 1520, Games: 1}, "c": {Rating: 1520, Games: 1}},
+		},
+		{
+			name:    "anonymous forfeit is not rated",
+			seats:   []*Seat{{Score: 0}, {PlayerID: "b", Score: 0}, {PlayerID: "c", Score: 0}},
+			before:  map[string]Rating{},
+			forfeit: 1,
+			after:   map[string]Rating{},
+		},
+	}
+
+	defer func(store RatingStore) { ratingStore = store }(ratingStore)
+
+	for _, test := range tests {
+		store := newJSONRatingStore("")
+		for id, rating := range test.before {
+			store.ratings[id] = rating
+		}
+		ratingStore = store
+
+		room := &Room{RoomName: "test", Seats: test.seats}
+		if test.forfeit != 0 {
+			forfeitRatings(room, test.forfeit)
+		} else {
+			updateRatings(room)
+		}
+
+		for id, want := range test.after {
+			got := store.ratings[id]
+			if math.Abs(got.Rating-want.Rating) > 0.01 || got.Games != want.Games {
+				t.Errorf("%s: %s got %.2f after %d games, want %.2f after %d", test.name, id, got.Rating, got.Games, want.Rating, want.Games)
+			}
+		}
+
+		for id, got := range store.ratings {
+			if _, ok := test.after[id]; !ok && got != test.before[id] {
+				t.Errorf("%s: %s changed to %.2f but should not be rated", test.name, id, got.Rating)
+			}
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// how long the players of a finished game have to agree on a rematch, and the
+// break between the boards of a best-of series
+const (
+	REMATCH_WINDOW = 60 * time.Second
+	SERIES_BREAK   = 10 * time.Second
+)
+
+// Rematch is the offer to play again that every finished game leaves behind
+type Rematch struct {
+	RoomName  string       // of the finished game, which its players are still bound to
+	Clients   []*WSClient  // in seat order
+	Seats     []*Seat      // the finished game's seats, for their series indexes
+	Settings  GameSettings // settings of the next game
+	SwapFirst bool         // the player who went first goes last next game
+	Series    *Series
+	Requested bool               // someone asked for a rematch
+	Agreed    map[*WSClient]bool // players who asked for or accepted it
+	Timer     *time.Timer
+}
+
+var (
+	rematches     = make(map[string]*Rematch) // finished room name -> offer
+	rematchesLock sync.Mutex
+)
+
+// offerRematch lets the players of a finished game start another one together
+// within REMATCH_WINDOW. In a best-of series the next board is agreed already
+// and starts after SERIES_BREAK, and a finished series gets no offer. Neither
+// do games someone is still disconnected from, which also ends their series
+func offerRematch(room *Room, series *Series) {
+	if series.finished() {
+		return
+	}
+
+	rematch := &Rematch{
+		RoomName: room.RoomName,
+		Seats:    room.Seats,
+		Settings: roomSettings(room),
+		Series:   series,
+		Agreed:   make(map[*WSClient]bool),
+	}
+
+	room.RoomLock.Lock()
+
+	for _, seat := range room.Seats {
+		if seat.ResumeTimer != nil {
+			room.RoomLock.Unlock()
+
+			if series.bestOf() {
+				broadcastToRoom(room, StatusMessage{Type: "seriesAborted"})
+			}
+
+			return
+		}
+
+		rematch.Clients = append(rematch.Clients, seat.Client)
+	}
+
+	room.RoomLock.Unlock()
+
+	rematchesLock.Lock()
+	defer rematchesLock.Unlock()
+
+	rematches[room.RoomName] = rematch
+
+	if series.bestOf() {
+		// boards take turns on who goes first, and a seed or invites were only
+		// for the first, so every board after it is a fresh one
+		rematch.Settings = series.Settings
+		rematch.Settings.Seed = 0
+		rematch.Settings.Seeded = false
+		rematch.Settings.Invites = 0
+		rematch.SwapFirst = true
+		rematch.Requested = true
+
+		for _, client := range rematch.Clients {
+			rematch.Agreed[client] = true
+		}
+
+		rematch.Timer = time.AfterFunc(SERIES_BREAK, func() {
+			rematchesLock.Lock()
+
+			if rematches[rematch.RoomName] != rematch {
+				rematchesLock.Unlock()
+				return
+			}
+
+			delete(rematches, rematch.RoomName)
+			rematchesLock.Unlock()
+
+			startRematch(rematch)
+		})
+
+		return
+	}
+
+	rematch.Timer = time.AfterFunc(REMATCH_WINDOW, func() {
+		rematchesLock.Lock()
+		defer rematchesLock.Unlock()
+
+		if rematches[rematch.RoomName] != rematch {
+			return
+		}
+
+		delete(rematches, rematch.RoomName)
+
+		if rematch.Requested {
+			rematch.broadcast(StatusMessage{Type: "rematchExpired"})
+		}
+	})
+}
+
+// broadcast sends a message to every player still bound to the finished game.
+// Caller must hold rematchesLock
+func (r *Rematch) broadcast(message interface{}) {
+	for _, client := range r.Clients {
+		if client.RoomName == r.RoomName {
+			client.WriteJSON(message)
+		}
+	}
+}
+
+// includes reports whether c played in the finished game
+func (r *Rematch) includes(c *WSClient) bool {
+	for _, client := range r.Clients {
+		if client == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findRematch returns the offer c can answer. Caller must hold rematchesLock
+func (c *WSClient) findRematch() (*Rematch, error) {
+	rematch, exists := rematches[c.RoomName]
+	if !exists || !rematch.includes(c) {
+		return nil, &ProtocolError{Code: "noRematch", Message: "there is no finished game to rematch"}
+	}
+
+	if rematch.Series.bestOf() {
+		return nil, &ProtocolError{Code: "noRematch", Message: "the series moves on to the next board by itself"}
+	}
+
+	return rematch, nil
+}
+
+func handleRequestRematch(c *WSClient, msg RequestRematchMessage) error {
+	if msg.Players != nil {
+		return &ProtocolError{Code: "invalidPlayers", Message: "a rematch keeps the same players"}
+	}
+
+	rematchesLock.Lock()
+	defer rematchesLock.Unlock()
+
+	rematch, err := c.findRematch()
+	if err != nil {
+		return err
+	}
+
+	if err := c.checkOptions(msg.GameOptions); err != nil {
+		return err
+	}
+
+	// anything left out stays as it was last game
+	options := msg.GameOptions
+	if options.Dictionary == "" {
+		options.Dictionary = rematch.Settings.Dictionary
+	}
+	if options.Board == "" {
+		options.Board = rematch.Settings.Board
+	}
+	if options.Mode == "" {
+		options.Mode = rematch.Settings.Mode
+	}
+	if options.Timer == nil {
+		options.Timer = &rematch.Settings.Timer
+	}
+	options.Players = &rematch.Settings.Capacity
+
+	settings, err := options.settings()
+	if err != nil {
+		return err
+	}
+
+	// a private game stays private
+	settings.Passcode = rematch.Settings.Passcode
+
+	// a new proposal needs everyone to agree again
+	rematch.Settings = settings
+	// nobody goes first in a simultaneous game, so there is nothing to swap
+	rematch.SwapFirst = settings.Mode == MODE_TURNS && msg.SwapFirst
+	rematch.Requested = true
+	rematch.Agreed = map[*WSClient]bool{c: true}
+
+	rematch.broadcast(RematchRequestedMessage{
+		Type:       "rematchRequested",
+		Player:     c.Number,
+		SwapFirst:  rematch.SwapFirst,
+		Dictionary: settings.Dictionary,
+		Board:      settings.Board,
+		Mode:       settings.Mode,
+	})
+
+	return nil
+}
+
+func handleAcceptRematch(c *WSClient, msg AcceptRematchMessage) error {
+	rematchesLock.Lock()
+
+	rematch, err := c.findRematch()
+	if err != nil {
+		rematchesLock.Unlock()
+		return err
+	}
+
+	if !rematch.Requested {
+		rematchesLock.Unlock()
+		return &ProtocolError{Code: "noRematch", Message: "nobody asked for a rematch"}
+	}
+
+	rematch.Agreed[c] = true
+	rematch.broadcast(RematchAcceptedMessage{Type: "rematchAccepted", Player: c.Number})
+
+	if len(rematch.Agreed) < len(rematch.Clients) {
+		rematchesLock.Unlock()
+		return nil
+	}
+
+	delete(rematches, rematch.RoomName)
+	rematch.Timer.Stop()
+
+	rematchesLock.Unlock()
+
+	startRematch(rematch)
+
+	return nil
+}
+
+// startRematch moves the players of a finished game into a new room with a new
+// board and starts it, without them having to join. This is also how a best-of
+// series moves on to its next board
+func startRematch(rematch *Rematch) {
+	started := "rematchStarted"
+	if rematch.Series.bestOf() {
+		started = "nextBoard"
+	}
+
+	order := make([]int, len(rematch.Clients))
+	for i := range order {
+		order[i] = i
+	}
+
+	if rematch.SwapFirst {
+		order = append(order[1:], order[0])
+	}
+
+	roomName := makeID(15)
+
+	initGame(roomName, rematch.Settings)
+
+	clientRoomsLock.RLock()
+	room, exists := clientRooms[roomName]
+	clientRoomsLock.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.RoomLock.Lock()
+
+	room.Series = rematch.Series
+
+	for _, previous := range order {
+		c := rematch.Clients[previous]
+		c.RoomName = roomName
+		c.Number = len(room.Seats) + 1
+
+		seat := c.newSeat(c.Number)
+		seat.SeriesIndex = rematch.Seats[previous].SeriesIndex
+
+		// anonymous players keep their name even if they changed seats
+		if seat.PlayerID == "" {
+			seat.DisplayName = rematch.Seats[previous].DisplayName
+		}
+		room.Seats = append(room.Seats, seat)
+
+		c.WriteJSON(RematchStartedMessage{
+			Type:     started,
+			RoomName: roomName,
+			Series:   rematch.Series.score(),
+		})
+		c.WriteJSON(InitMessage{
+			Type:        "init",
+			Number:      c.Number,
+			Capacity:    room.Capacity,
+			ResumeToken: seat.Token,
+			DisplayName: seat.DisplayName,
+		})
+
+		publishEvent(room, EVENT_PLAYER_JOINED, c.Number, seatInfo(seat, c.Number))
+	}
+
+	room.RoomLock.Unlock()
+
+	fmt.Printf("%s of room %s started in room %s\n", started, rematch.RoomName, roomName)
+
+	startGame(room)
+}
+
+// leaveRematch withdraws c from the rematch offer of its finished game, which
+// calls the rematch off for everyone
+func (c *WSClient) leaveRematch() {
+	rematchesLock.Lock()
+	defer rematchesLock.Unlock()
+
+	rematch, exists := rematches[c.RoomName]
+	if !exists || !rematch.includes(c) {
+		return
+	}
+
+	delete(rematches, rematch.RoomName)
+	rematch.Timer.Stop()
+
+	cancelled := "rematchCancelled"
+	if rematch.Series.bestOf() {
+		cancelled = "seriesAborted"
+	}
+
+	if rematch.Requested {
+		for _, client := range rematch.Clients {
+			if client != c && client.RoomName == rematch.RoomName {
+				client.WriteJSON(StatusMessage{Type: cancelled})
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bounds on the playback speed a client can ask for
+const (
+	MIN_REPLAY_SPEED = 0.25
+	MAX_REPLAY_SPEED = 50
+)
+
+// longest pause between two events during playback, so idle stretches don't stall it
+const MAX_REPLAY_GAP = 5 * time.Second
+
+// replayRecorder collects every room's events as they are published and saves
+// the whole log to the replay store once the game is over. Replays are served
+// to anyone with the room code, so private games are never recorded
+type replayRecorder struct {
+	store   ReplayStore
+	logs    map[string][]GameEvent
+	private map[string]bool // rooms whose events are dropped until the game is over
+	lock    sync.Mutex
+}
+
+func newReplayRecorder(store ReplayStore) *replayRecorder {
+	return &replayRecorder{
+		store:   store,
+		logs:    make(map[string][]GameEvent),
+		private: make(map[string]bool),
+	}
+}
+
+func (r *replayRecorder) OpenRoom(room string) {}
+
+func (r *replayRecorder) Publish(event GameEvent) {
+	over := event.Type == EVENT_GAME_ENDED || event.Type == EVENT_GAME_ABORTED
+
+	r.lock.Lock()
+
+	if created, ok := event.Data.(GameCreatedEvent); ok && created.Private {
+		r.private[event.Room] = true
+	}
+
+	if r.private[event.Room] {
+		if over {
+			delete(r.private, event.Room)
+		}
+
+		r.lock.Unlock()
+		return
+	}
+
+	r.logs[event.Room] = append(r.logs[event.Room], event)
+
+	if !over {
+		r.lock.Unlock()
+		return
+	}
+
+	events := r.logs[event.Room]
+	delete(r.logs, event.Room)
+	r.lock.Unlock()
+
+	go func() {
+		if err := r.store.Save(event.Room, events); err != nil {
+			log.Printf("failed to save replay for %s: %s\n", event.Room, err.Error())
+		}
+	}()
+}
+
+func (r *replayRecorder) CloseRoom(room string) {}
+func (r *replayRecorder) Close() error          { return nil }
+
+// multiSink hands every call to each of its sinks in order
+type multiSink []EventSink
+
+func (m multiSink) OpenRoom(room string) {
+	for _, sink := range m {
+		sink.OpenRoom(room)
+	}
+}
+
+func (m multiSink) Publish(event GameEvent) {
+	for _, sink := range m {
+		sink.Publish(event)
+	}
+}
+
+func (m multiSink) CloseRoom(room string) {
+	for _, sink := range m {
+		sink.CloseRoom(room)
+	}
+}
+
+func (m multiSink) Close() error {
+	var first error
+	for _, sink := range m {
+		if err := sink.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// handleGames serves GET /games/{id}/replay
+func handleGames(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/games/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "replay" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	events, err := replayStore.Load(parts[0])
+	if err == ErrReplayNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("failed to load replay %s: %s\n", parts[0], err.Error())
+		http.Error(w, "failed to load replay", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReplayResponse{ID: parts[0], Events: events})
+}
+
+func handleReplay(c *WSClient, msg ReplayMessage) error {
+	speed := 1.0
+	if msg.Speed != nil {
+		speed = *msg.Speed
+	}
+
+	if speed < MIN_REPLAY_SPEED || speed > MAX_REPLAY_SPEED {
+		return &ProtocolError{Code: "invalidSpeed", Message: fmt.Sprintf("speed must be between %g and %g", MIN_REPLAY_SPEED, float64(MAX_REPLAY_SPEED))}
+	}
+
+	events, err := replayStore.Load(msg.GameID)
+	if err == ErrReplayNotFound {
+		return &ProtocolError{Code: "unknownReplay", Message: fmt.Sprintf("no replay for game %q", msg.GameID)}
+	} else if err != nil {
+		return err
+	}
+
+	// stream in the background so the connection keeps handling other messages
+	go streamReplay(c, msg.GameID, events, speed)
+
+	return nil
+}
+
+// streamReplay sends the events of a game to c, spaced out like they happened
+// but sped up by speed
+func streamReplay(c *WSClient, id string, events []GameEvent, speed float64) {
+	for i, event := range events {
+		if i > 0 {
+			gap := time.Duration(float64(event.Timestamp.Sub(events[i-1].Timestamp)) / speed)
+			if gap > MAX_REPLAY_GAP {
+				gap = MAX_REPLAY_GAP
+			}
+
+			time.Sleep(gap)
+		}
+
+		if err := c.WriteJSON(ReplayEventMessage{Type: "replayEvent", GameID: id, Event: event}); err != nil {
+			return
+		}
+	}
+
+	c.WriteJSON(ReplayEndMessage{Type: "replayEnd", GameID: id})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+var ErrReplayNotFound = errors.New("replay not found")
+
+// most games the memory store holds before dropping the least recently used
+const MAX_MEMORY_REPLAYS = 500
+
+// game ids are room codes from makeID, which keeps them safe to use as file names
+var validGameID = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// ReplayStore keeps the full event log of finished games
+type ReplayStore interface {
+	Save(id string, events []GameEvent) error
+	// Load returns ErrReplayNotFound if no game with that id was saved
+	Load(id string) ([]GameEvent, error)
+}
+
+// replayStore is chosen by newReplayStore at startup
+var replayStore ReplayStore = newMemoryReplayStore(MAX_MEMORY_REPLAYS)
+
+func newReplayStore(cfg Config) (ReplayStore, error) {
+	switch cfg.ReplayStore {
+	case "memory", "":
+		return newMemoryReplayStore(MAX_MEMORY_REPLAYS), nil
+	case "file":
+		return newFileReplayStore(cfg.ReplayDir)
+	default:
+		return nil, fmt.Errorf("unknown replay store %q", cfg.ReplayStore)
+	}
+}
+
+// memoryReplayStore keeps the most recently saved or loaded replays until the
+// process exits, so a long-running server does not grow without bound
+type memoryReplayStore struct {
+	limit  int
+	games  map[string]*list.Element // id -> element in recent
+	recent *list.List               // of *memoryReplay, most recently used first
+	lock   sync.Mutex
+}
+
+type memoryReplay struct {
+	id     string
+	events []GameEvent
+}
+
+func newMemoryReplayStore(limit int) *memoryReplayStore {
+	return &memoryReplayStore{
+		limit:  limit,
+		games:  make(map[string]*list.Element),
+		recent: list.New(),
+	}
+}
+
+func (s *memoryReplayStore) Save(id string, events []GameEvent) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if element, exists := s.games[id]; exists {
+		element.Value.(*memoryReplay).events = events
+		s.recent.MoveToFront(element)
+		return nil
+	}
+
+	s.games[id] = s.recent.PushFront(&memoryReplay{id: id, events: events})
+
+	for s.recent.Len() > s.limit {
+		oldest := s.recent.Back()
+		s.recent.Remove(oldest)
+		delete(s.games, oldest.Value.(*memoryReplay).id)
+	}
+
+	return nil
+}
+
+func (s *memoryReplayStore) Load(id string) ([]GameEvent, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	element, exists := s.games[id]
+	if !exists {
+		return nil, ErrReplayNotFound
+	}
+
+	s.recent.MoveToFront(element)
+	return element.Value.(*memoryReplay).events, nil
+}
+
+// fileReplayStore writes each game to its own JSON file in a directory
+type fileReplayStore struct {
+	dir string
+}
+
+func newFileReplayStore(dir string) (*fileReplayStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &fileReplayStore{dir: dir}, nil
+}
+
+func (s *fileReplayStore) path(id string) (string, error) {
+	if !validGameID.MatchString(id) {
+		return "", ErrReplayNotFound
+	}
+
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *fileReplayStore) Save(id string, events []GameEvent) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	// write then rename so a crash never leaves half a replay behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, encoded, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (s *fileReplayStore) Load(id string) ([]GameEvent, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrReplayNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var events []GameEvent
+	if err := json.Unmarshal(encoded, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
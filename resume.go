@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// how long a disconnected player has to come back before the game is ended
+const RESUME_GRACE = 60 * time.Second
+
+// makeResumeToken returns an unguessable token a player can later use to take
+// their seat back after losing their connection
+func makeResumeToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// fall back to a room style id rather than handing out no token at all
+		return makeID(32)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// holdsSeat reports whether c is the connection currently playing one of the
+// room's seats. Caller must hold room.RoomLock
+func (c *WSClient) holdsSeat(room *Room) bool {
+	return !c.Spectating && c.Number >= 1 && c.Number <= len(room.Seats) && room.Seats[c.Number-1].Client == c
+}
+
+// startResumeGrace keeps the room alive for RESUME_GRACE after c dropped. It
+// reports false if c no longer owns its seat or a grace window is already running.
+// Caller must hold room.RoomLock
+func (c *WSClient) startResumeGrace(room *Room) bool {
+	if !c.holdsSeat(room) {
+		return false
+	}
+
+	seat := room.Seats[c.Number-1]
+	if seat.ResumeTimer != nil {
+		return false
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(RESUME_GRACE, func() {
+		room.RoomLock.Lock()
+
+		// the player came back just as the window closed
+		if seat.ResumeTimer != timer {
+			room.RoomLock.Unlock()
+			return
+		}
+
+		seat.ResumeTimer = nil
+		room.RoomLock.Unlock()
+
+		fmt.Printf("%d did not resume in time\n", c.UniqueNumber)
+
+		c.endRoom(c.Number)
+	})
+
+	seat.ResumeTimer = timer
+
+	return true
+}
+
+func (c *WSClient) resumeGame(roomName string, token string) {
+	clientRoomsLock.RLock()
+	room, exists := clientRooms[roomName]
+	clientRoomsLock.RUnlock()
+
+	if !exists {
+		c.WriteJSON(StatusMessage{Type: "unknownGame"})
+		return
+	}
+
+	room.RoomLock.Lock()
+
+	number := 0
+	for i, seat := range room.Seats {
+		if token != "" && token == seat.Token {
+			number = i + 1
+			break
+		}
+	}
+
+	if number == 0 {
+		room.RoomLock.Unlock()
+		c.WriteJSON(StatusMessage{Type: "invalidResumeToken"})
+		return
+	}
+
+	seat := room.Seats[number-1]
+	if seat.ResumeTimer != nil {
+		seat.ResumeTimer.Stop()
+		seat.ResumeTimer = nil
+	}
+
+	c.Number = number
+	c.RoomName = roomName
+	seat.Client = c
+
+	resumed := ResumedMessage{
+		Type:          "resumed",
+		Number:        number,
+		CurrentPlayer: room.CurrentPlayer,
+		Countdown:     countdownFor(time.Until(room.TurnDeadline)),
+		Words:         append([]string(nil), seat.Words...),
+		GameInfo:      gameInfo(room),
+	}
+
+	publishEvent(room, EVENT_PLAYER_RESUMED, number, nil)
+
+	room.RoomLock.Unlock()
+
+	c.WriteJSON(resumed)
+
+	broadcastOpponentStatus(room, number, "opponentReconnected")
+
+	fmt.Printf("%d resumed as player %d in room %s\n", c.UniqueNumber, c.Number, c.RoomName)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// bounds on the length of a private room's passcode
+const (
+	MIN_PASSCODE_LENGTH = 4
+	MAX_PASSCODE_LENGTH = 64
+)
+
+func checkPasscode(room *Room, passcode string) bool {
+	return subtle.ConstantTimeCompare([]byte(room.Passcode), []byte(passcode)) == 1
+}
+
+// admit checks whether a player may take a seat in the room, using up the
+// invite token if that is what let them in. Caller must hold room.RoomLock
+func admit(room *Room, passcode string, invite string) bool {
+	if !room.Private {
+		return true
+	}
+
+	if room.Passcode != "" && checkPasscode(room, passcode) {
+		return true
+	}
+
+	if invite != "" && room.Invites[invite] {
+		room.Invites[invite] = false
+		return true
+	}
+
+	return false
+}
+
+// canWatch checks whether someone may spectate the room. Private rooms take
+// their passcode or any invite issued for them, used or not, without using it
+// up. Caller must hold room.RoomLock
+func canWatch(room *Room, passcode string, invite string) bool {
+	if !room.Private {
+		return true
+	}
+
+	if room.Passcode != "" && checkPasscode(room, passcode) {
+		return true
+	}
+
+	_, issued := room.Invites[invite]
+	return invite != "" && issued
+}
+
+// RoomSettings are the settings of a room shown in an invite preview
+type RoomSettings struct {
+	Dictionary string `json:"dictionary"`
+	Board      string `json:"board"`
+	Size       int    `json:"size"`
+	Mode       string `json:"mode"`
+	Countdown  [2]int `json:"countdown"` // length of a turn, or of the whole game when simultaneous
+}
+
+// RoomPreview is what GET /rooms/{code} tells anyone holding a room code
+type RoomPreview struct {
+	Code     string        `json:"code"`
+	Exists   bool          `json:"exists"`
+	Full     bool          `json:"full,omitempty"`
+	Private  bool          `json:"private,omitempty"` // joining needs a passcode or invite
+	Players  int           `json:"players,omitempty"`
+	Capacity int           `json:"capacity,omitempty"`
+	Settings *RoomSettings `json:"settings,omitempty"`
+}
+
+// handleRooms serves GET /rooms/{code}
+func handleRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeHTTPError(w, http.StatusMethodNotAllowed, "methodNotAllowed", "use GET")
+		return
+	}
+
+	code := strings.Trim(strings.TrimPrefix(r.URL.Path, "/rooms/"), "/")
+	if code == "" || strings.Contains(code, "/") {
+		writeHTTPError(w, http.StatusNotFound, "notFound", "unknown room endpoint")
+		return
+	}
+
+	clientRoomsLock.RLock()
+	room, exists := clientRooms[code]
+	clientRoomsLock.RUnlock()
+
+	if !exists {
+		writeJSON(w, http.StatusNotFound, RoomPreview{Code: code})
+		return
+	}
+
+	room.RoomLock.Lock()
+
+	preview := RoomPreview{
+		Code:     code,
+		Exists:   true,
+		Full:     len(room.Seats) >= room.Capacity,
+		Private:  room.Private,
+		Players:  len(room.Seats),
+		Capacity: room.Capacity,
+		Settings: &RoomSettings{
+			Dictionary: room.Dictionary,
+			Board:      room.Board,
+			Size:       room.Size,
+			Mode:       room.Mode,
+			Countdown:  room.Countdown,
+		},
+	}
+
+	room.RoomLock.Unlock()
+
+	writeJSON(w, http.StatusOK, preview)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go_boggle_server/boards"
+)
+
+// ProtocolError is returned by message handlers when a message cannot be
+// handled. It is sent back to the client as an ErrorMessage
+type ProtocolError struct {
+	Code    string
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+type messageHandler func(c *WSClient, payload []byte) error
+
+// handle adapts a handler for one message struct into a messageHandler
+func handle[T any](fn func(c *WSClient, msg T) error) messageHandler {
+	return func(c *WSClient, payload []byte) error {
+		var msg T
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return &ProtocolError{Code: "malformedMessage", Message: err.Error()}
+		}
+
+		return fn(c, msg)
+	}
+}
+
+// messageHandlers maps every inbound message type to its handler
+var messageHandlers = map[string]messageHandler{
+	"hello":          handle(handleHello),
+	"authenticate":   handle(handleAuthenticate),
+	"newGame":        handle(handleNewGame),
+	"joinGame":       handle(handleJoinGame),
+	"randomGame":     handle(handleRandomGame),
+	"cancelRandom":   handle(handleCancelRandom),
+	"requestRematch": handle(handleRequestRematch),
+	"acceptRematch":  handle(handleAcceptRematch),
+	"submitWord":     handle(handleSubmitWord),
+	"spectateGame":   handle(handleSpectateGame),
+	"resume":         handle(handleResume),
+	"replay":         handle(handleReplay),
+}
+
+// route decodes the envelope of a raw message and dispatches it to its handler,
+// replying with an error if that fails
+func (c *WSClient) route(payload []byte) {
+	var envelope Envelope
+	if err := json.Unmarshal(payload, &envelope); err != nil || envelope.Type == "" {
+		c.WriteJSON(ErrorMessage{
+			Type:    "error",
+			Code:    "malformedMessage",
+			Message: "message must be a JSON object with a type",
+		})
+		return
+	}
+
+	handler, ok := messageHandlers[envelope.Type]
+	if !ok {
+		c.WriteJSON(ErrorMessage{
+			Type:    "error",
+			Code:    "unknownMessage",
+			Message: fmt.Sprintf("unknown message type %q", envelope.Type),
+			For:     envelope.Type,
+		})
+		return
+	}
+
+	var err error
+	if feature, gated := messageFeatures[envelope.Type]; gated {
+		err = c.requireFeature(feature)
+	}
+
+	if err == nil {
+		err = handler(c, payload)
+	}
+
+	if err != nil {
+		reply := ErrorMessage{Type: "error", Code: "internalError", Message: err.Error(), For: envelope.Type}
+
+		if protocolErr, ok := err.(*ProtocolError); ok {
+			reply.Code = protocolErr.Code
+			reply.Message = protocolErr.Message
+		}
+
+		c.WriteJSON(reply)
+	}
+}
+
+// settings validates the options of a newGame or randomGame message, filling
+// in defaults for anything left out
+func (o GameOptions) settings() (GameSettings, error) {
+	settings := GameSettings{
+		Dictionary: boards.DefaultDictionary,
+		Board:      DEFAULT_BOARD,
+		Capacity:   MIN_PLAYERS,
+		Mode:       MODE_TURNS,
+		Timer:      DEFAULT_TIMER,
+	}
+
+	if o.Dictionary != "" {
+		if !boards.IsKnown(o.Dictionary) {
+			return settings, &ProtocolError{Code: "unknownDictionary", Message: fmt.Sprintf("unknown dictionary %q", o.Dictionary)}
+		}
+
+		settings.Dictionary = o.Dictionary
+	}
+
+	if o.Board != "" {
+		if _, ok := BOARD_VERSIONS[o.Board]; !ok {
+			return settings, &ProtocolError{Code: "unknownBoard", Message: fmt.Sprintf("unknown board %q", o.Board)}
+		}
+
+		settings.Board = o.Board
+	}
+
+	if o.Mode != "" {
+		if !MODES[o.Mode] {
+			return settings, &ProtocolError{Code: "unknownMode", Message: fmt.Sprintf("unknown mode %q", o.Mode)}
+		}
+
+		settings.Mode = o.Mode
+	}
+
+	if o.Players != nil {
+		if *o.Players < MIN_PLAYERS || *o.Players > MAX_PLAYERS {
+			return settings, &ProtocolError{Code: "invalidPlayers", Message: fmt.Sprintf("players must be between %d and %d", MIN_PLAYERS, MAX_PLAYERS)}
+		}
+
+		settings.Capacity = *o.Players
+	}
+
+	if o.Timer != nil {
+		if *o.Timer < MIN_TIMER || *o.Timer > MAX_TIMER {
+			return settings, &ProtocolError{Code: "invalidTimer", Message: fmt.Sprintf("timer must be between %d and %d seconds", MIN_TIMER, MAX_TIMER)}
+		}
+
+		settings.Timer = *o.Timer
+	}
+
+	return settings, nil
+}
+
+func handleNewGame(c *WSClient, msg NewGameMessage) error {
+	if err := c.checkOptions(msg.GameOptions); err != nil {
+		return err
+	}
+
+	settings, err := msg.settings()
+	if err != nil {
+		return err
+	}
+
+	// a seed lets friends replay a previous board
+	if msg.Seed != nil {
+		if err := c.requireFeature("seeds"); err != nil {
+			return err
+		}
+
+		if *msg.Seed < 0 || *msg.Seed >= MAX_SEED {
+			return &ProtocolError{Code: "invalidSeed", Message: fmt.Sprintf("seed must be between 0 and %d", int64(MAX_SEED-1))}
+		}
+
+		settings.Seed = *msg.Seed
+		settings.Seeded = true
+	}
+
+	if msg.Passcode != "" && (len(msg.Passcode) < MIN_PASSCODE_LENGTH || len(msg.Passcode) > MAX_PASSCODE_LENGTH) {
+		return &ProtocolError{Code: "invalidPasscode", Message: fmt.Sprintf("passcode must be between %d and %d characters", MIN_PASSCODE_LENGTH, MAX_PASSCODE_LENGTH)}
+	}
+
+	// there is no point inviting more players than the room has seats for
+	if msg.Invites < 0 || msg.Invites > settings.Capacity-1 {
+		return &ProtocolError{Code: "invalidInvites", Message: fmt.Sprintf("invites must be between 0 and %d", settings.Capacity-1)}
+	}
+
+	if msg.Passcode != "" || msg.Invites > 0 {
+		if err := c.requireFeature("privateRooms"); err != nil {
+			return err
+		}
+	}
+
+	settings.Passcode = msg.Passcode
+	settings.Invites = msg.Invites
+
+	// odd lengths mean two players splitting the boards cannot end level, but
+	// drawn boards still can, and then the series finishes with no winner
+	if msg.BestOf != 0 && (msg.BestOf < 1 || msg.BestOf > MAX_BEST_OF || msg.BestOf%2 == 0) {
+		return &ProtocolError{Code: "invalidBestOf", Message: fmt.Sprintf("bestOf must be an odd number up to %d", MAX_BEST_OF)}
+	}
+
+	if msg.BestOf > 1 {
+		if err := c.requireFeature("series"); err != nil {
+			return err
+		}
+	}
+
+	settings.BestOf = msg.BestOf
+
+	c.leaveSpectating()
+	c.leaveQueue()
+	c.leaveRematch()
+	c.newGame(settings)
+
+	return nil
+}
+
+func handleJoinGame(c *WSClient, msg JoinGameMessage) error {
+	c.leaveSpectating()
+	c.leaveQueue()
+	c.leaveRematch()
+	c.joinGame(msg.RoomName, msg.Passcode, msg.Invite)
+
+	return nil
+}
+
+func handleRandomGame(c *WSClient, msg RandomGameMessage) error {
+	if err := c.checkOptions(msg.GameOptions); err != nil {
+		return err
+	}
+
+	settings, err := msg.settings()
+	if err != nil {
+		return err
+	}
+
+	c.leaveSpectating()
+	c.leaveRematch()
+	c.randomGame(settings)
+
+	return nil
+}
+
+func handleSubmitWord(c *WSClient, msg SubmitWordMessage) error {
+	c.submitWord(msg)
+
+	return nil
+}
+
+func handleSpectateGame(c *WSClient, msg SpectateGameMessage) error {
+	if c.seated() {
+		return &ProtocolError{Code: "alreadyPlaying", Message: "players cannot spectate while their game is on"}
+	}
+
+	c.leaveSpectating()
+	c.leaveQueue()
+	c.leaveRematch()
+	c.spectateGame(msg.RoomName, msg.Passcode, msg.Invite)
+
+	return nil
+}
+
+func handleResume(c *WSClient, msg ResumeMessage) error {
+	c.leaveSpectating()
+	c.leaveQueue()
+	c.leaveRematch()
+	c.resumeGame(msg.RoomName, msg.Token)
+
+	return nil
+}
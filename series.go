@@ -0,0 +1,172 @@
+package main
+
+// longest best-of series newGame accepts
+const MAX_BEST_OF = 7
+
+// Series keeps score across consecutive games between the same players: a
+// best-of series started by newGame, or a game and its rematches. Players keep
+// their series index however seats are reordered between games
+type Series struct {
+	Settings GameSettings // a best-of series plays every board with these
+	Players  []PlayerInfo // in the seat order of the first game, without seat numbers
+	Wins     []int        // by series index
+	Draws    int
+	Games    int
+	Results  []BoardResult
+}
+
+// BoardResult is the outcome of one game in a series
+type BoardResult struct {
+	Game     int       `json:"game"` // 1-based
+	RoomName string    `json:"roomName"`
+	Scores   []float64 `json:"scores"` // by series index
+	Winner   int       `json:"winner"` // 1-based series index, 0 for a draw
+}
+
+// SeriesScore is the standing of a series sent to its players
+type SeriesScore struct {
+	Players  []PlayerInfo  `json:"players"`
+	Wins     []int         `json:"wins"`
+	Draws    int           `json:"draws"`
+	Games    int           `json:"games"`
+	BestOf   int           `json:"bestOf,omitempty"` // left out for rematches
+	Results  []BoardResult `json:"results"`
+	Finished bool          `json:"finished,omitempty"`
+	Winner   int           `json:"winner,omitempty"` // 1-based series index once finished, 0 if level
+}
+
+func newSeries(settings GameSettings) *Series {
+	return &Series{Settings: settings}
+}
+
+// seat takes the players of the series' first game, giving every seat its
+// series index
+func (s *Series) seat(room *Room) {
+	s.Players = make([]PlayerInfo, len(room.Seats))
+	s.Wins = make([]int, len(room.Seats))
+
+	for i, seat := range room.Seats {
+		seat.SeriesIndex = i
+		s.Players[i] = PlayerInfo{ID: seat.PlayerID, DisplayName: seat.DisplayName}
+	}
+}
+
+// winner returns the seat with the highest score, or nil if the top score is shared
+func winner(room *Room) *Seat {
+	var best *Seat
+	tied := false
+
+	for _, seat := range room.Seats {
+		if best == nil || seat.Score > best.Score {
+			best, tied = seat, false
+		} else if seat.Score == best.Score {
+			tied = true
+		}
+	}
+
+	if tied {
+		return nil
+	}
+
+	return best
+}
+
+// record adds the result of a finished game in the series
+func (s *Series) record(room *Room) {
+	if s.Players == nil {
+		s.seat(room)
+	}
+
+	s.Games++
+
+	result := BoardResult{
+		Game:     s.Games,
+		RoomName: room.RoomName,
+		Scores:   make([]float64, len(s.Players)),
+	}
+
+	for _, seat := range room.Seats {
+		result.Scores[seat.SeriesIndex] = seat.Score
+	}
+
+	if best := winner(room); best != nil {
+		s.Wins[best.SeriesIndex]++
+		result.Winner = best.SeriesIndex + 1
+	} else {
+		s.Draws++
+	}
+
+	s.Results = append(s.Results, result)
+}
+
+// bestOf reports whether this is a best-of series rather than rematches
+func (s *Series) bestOf() bool {
+	return s.Settings.BestOf > 1
+}
+
+// leader returns the 1-based series index of the player with the most wins,
+// or 0 if that is shared
+func (s *Series) leader() int {
+	leader, tied := 0, false
+
+	for i, wins := range s.Wins {
+		if leader == 0 || wins > s.Wins[leader-1] {
+			leader, tied = i+1, false
+		} else if wins == s.Wins[leader-1] {
+			tied = true
+		}
+	}
+
+	if tied {
+		return 0
+	}
+
+	return leader
+}
+
+// finished reports whether a best-of series is over, either because every
+// board was played or because nobody can catch the leader any more
+func (s *Series) finished() bool {
+	if !s.bestOf() {
+		return false
+	}
+
+	if s.Games >= s.Settings.BestOf {
+		return true
+	}
+
+	leader := s.leader()
+	if leader == 0 {
+		return false
+	}
+
+	remaining := s.Settings.BestOf - s.Games
+	for i, wins := range s.Wins {
+		if i != leader-1 && wins+remaining >= s.Wins[leader-1] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Series) score() *SeriesScore {
+	score := &SeriesScore{
+		Players: s.Players,
+		Wins:    append([]int(nil), s.Wins...),
+		Draws:   s.Draws,
+		Games:   s.Games,
+		Results: append([]BoardResult(nil), s.Results...),
+	}
+
+	if s.bestOf() {
+		score.BestOf = s.Settings.BestOf
+		score.Finished = s.finished()
+
+		if score.Finished {
+			score.Winner = s.leader()
+		}
+	}
+
+	return score
+}
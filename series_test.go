@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeries(t *testing.T) {
+	tests := []struct {
+		name     string
+		bestOf   int
+		boards   [][]float64 // scores of every board, by series index
+		wins     []int
+		draws    int
+		finished bool
+		winner   int // 1-based series index, 0 if the series is level or not over
+	}{
+		{
+			name:   "one board in",
+			bestOf: 3,
+			boards: [][]float64{{5, 2}},
+			wins:   []int{1, 0},
+		},
+		{
+			name:     "decided before the last board",
+			bestOf:   3,
+			boards:   [][]float64{{5, 2}, {7, 1}},
+			wins:     []int{2, 0},
+			finished: true,
+			winner:   1,
+		},
+		{
+			name:     "won on the last board",
+			bestOf:   3,
+			boards:   [][]float64{{5, 2}, {1, 7}, {0, 3}},
+			wins:     []int{1, 2},
+			finished: true,
+			winner:   2,
+		},
+		{
+			name:   "a draw keeps the trailing player in it",
+			bestOf: 5,
+			boards: [][]float64{{5, 2}, {5, 2}, {4, 4}},
+			wins:   []int{2, 0},
+			draws:  1,
+		},
+		{
+			name:     "drawn boards can end a series level",
+			bestOf:   3,
+			boards:   [][]float64{{3, 3}, {0, 0}, {2, 2}},
+			wins:     []int{0, 0},
+			draws:    3,
+			finished: true,
+		},
+		{
+			name:     "three players, shared top score is a draw",
+			bestOf:   3,
+			boards:   [][]float64{{4, 4, 1}, {6, 2, 1}, {1, 2, 3}},
+			wins:     []int{1, 0, 1},
+			draws:    1,
+			finished: true,
+		},
+		{
+			name:   "rematches never finish",
+			boards: [][]float64{{5, 2}, {7, 1}, {9, 0}},
+			wins:   []int{3, 0},
+		},
+	}
+
+	for _, test := range tests {
+		series := newSeries(GameSettings{BestOf: test.bestOf})
+
+		for i, board := range test.boards {
+			room := &Room{RoomName: "board"}
+			for _, score := range board {
+				room.Seats = append(room.Seats, &Seat{Score: score})
+			}
+
+			// later boards swap seats around, which the series index undoes
+			if i > 0 {
+				for j, seat := range room.Seats {
+					seat.SeriesIndex = j
+				}
+				room.Seats = append(room.Seats[1:], room.Seats[0])
+			}
+
+			series.record(room)
+		}
+
+		score := series.score()
+
+		if !reflect.DeepEqual(score.Wins, test.wins) || score.Draws != test.draws {
+			t.Errorf("%s: got wins %v and %d draws, want %v and %d", test.name, score.Wins, score.Draws, test.wins, test.draws)
+		}
+
+		if score.Finished != test.finished || score.Winner != test.winner {
+			t.Errorf("%s: got finished %t with winner %d, want %t with %d", test.name, score.Finished, score.Winner, test.finished, test.winner)
+		}
+
+		if score.Games != len(test.boards) || len(score.Results) != len(test.boards) {
+			t.Errorf("%s: got %d games and %d results, want %d", test.name, score.Games, len(score.Results), len(test.boards))
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// EventSink receives the events describing what happens in each room
+type EventSink interface {
+	// OpenRoom prepares the sink for a new room. It must not block on the network
+	OpenRoom(room string)
+	Publish(event GameEvent)
+	// CloseRoom is called once a room's game is over
+	CloseRoom(room string)
+	Close() error
+}
+
+// eventSink is chosen by newEventSink at startup
+var eventSink EventSink = noopSink{}
+
+func newEventSink(cfg Config) (EventSink, error) {
+	switch cfg.EventSink {
+	case "none", "":
+		return noopSink{}, nil
+	case "stdout":
+		return stdoutSink{}, nil
+	case "file":
+		return newFileSink(cfg.EventFile)
+	case "kafka":
+		broker, err := newRemoteBroker(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return newKafkaSink(cfg, broker), nil
+	case "memory":
+		return newKafkaSink(cfg, newMemoryBroker(MEMORY_BROKER_PARTITIONS)), nil
+	default:
+		return nil, fmt.Errorf("unknown event sink %q", cfg.EventSink)
+	}
+}
+
+// noopSink drops every event
+type noopSink struct{}
+
+func (noopSink) OpenRoom(room string)    {}
+func (noopSink) Publish(event GameEvent) {}
+func (noopSink) CloseRoom(room string)   {}
+func (noopSink) Close() error            { return nil }
+
+// stdoutSink prints every event as a line of JSON, handy for local development
+type stdoutSink struct{}
+
+func (stdoutSink) OpenRoom(room string) {}
+
+func (stdoutSink) Publish(event GameEvent) {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode %s event: %s\n", event.Type, err.Error())
+		return
+	}
+
+	fmt.Println(string(encoded))
+}
+
+func (stdoutSink) CloseRoom(room string) {}
+func (stdoutSink) Close() error          { return nil }
+
+// fileSink appends every event to a file as JSON lines
+type fileSink struct {
+	file *os.File
+	lock sync.Mutex
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) OpenRoom(room string) {}
+
+func (s *fileSink) Publish(event GameEvent) {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode %s event: %s\n", event.Type, err.Error())
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.file.Write(append(encoded, '\n'))
+}
+
+func (s *fileSink) CloseRoom(room string) {}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// most spectators a single room will accept
+const MAX_SPECTATORS = 10
+
+func (c *WSClient) spectateGame(roomName string, passcode string, invite string) {
+	clientRoomsLock.RLock()
+	room, exists := clientRooms[roomName]
+	clientRoomsLock.RUnlock()
+
+	if !exists {
+		c.WriteJSON(StatusMessage{Type: "unknownGame"})
+		return
+	}
+
+	room.RoomLock.Lock()
+
+	if len(room.Spectators) >= MAX_SPECTATORS {
+		room.RoomLock.Unlock()
+		c.WriteJSON(StatusMessage{Type: "tooManySpectators"})
+		return
+	}
+
+	if !canWatch(room, passcode, invite) {
+		room.RoomLock.Unlock()
+		c.WriteJSON(StatusMessage{Type: "accessDenied"})
+		return
+	}
+
+	c.RoomName = roomName
+	c.Number = 0
+	c.Spectating = true
+	room.Spectators = append(room.Spectators, c)
+
+	// catch the spectator up on whatever already happened
+	spectating := SpectatingMessage{
+		Type:          "spectating",
+		Started:       len(room.Seats) == room.Capacity,
+		CurrentPlayer: room.CurrentPlayer,
+		Countdown:     countdownFor(time.Until(room.TurnDeadline)),
+		Scores:        scores(room),
+		GameInfo:      gameInfo(room),
+	}
+
+	count := len(room.Spectators)
+
+	room.RoomLock.Unlock()
+
+	c.WriteJSON(spectating)
+
+	broadcastSpectators(room, count)
+
+	fmt.Printf("%d is spectating room %s\n", c.UniqueNumber, c.RoomName)
+}
+
+// stopSpectating detaches a spectator from its room without affecting the game
+func (c *WSClient) stopSpectating() {
+	clientRoomsLock.RLock()
+	room, exists := clientRooms[c.RoomName]
+	clientRoomsLock.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.RoomLock.Lock()
+
+	for i, spectator := range room.Spectators {
+		if spectator == c {
+			room.Spectators = append(room.Spectators[:i], room.Spectators[i+1:]...)
+			break
+		}
+	}
+
+	count := len(room.Spectators)
+
+	room.RoomLock.Unlock()
+
+	broadcastSpectators(room, count)
+
+	fmt.Printf("%d stopped spectating room %s\n", c.UniqueNumber, c.RoomName)
+}
+
+// seated reports whether c holds a seat in a game that is still on
+func (c *WSClient) seated() bool {
+	if c.RoomName == "" {
+		return false
+	}
+
+	clientRoomsLock.RLock()
+	room, exists := clientRooms[c.RoomName]
+	clientRoomsLock.RUnlock()
+
+	if !exists {
+		return false
+	}
+
+	room.RoomLock.Lock()
+	defer room.RoomLock.Unlock()
+
+	return c.holdsSeat(room)
+}
+
+// leaveSpectating stops c watching its room before it takes a seat anywhere,
+// leaving it with no room until it gets one
+func (c *WSClient) leaveSpectating() {
+	if !c.Spectating {
+		return
+	}
+
+	c.stopSpectating()
+	c.Spectating = false
+	c.RoomName = ""
+	c.Number = -1
+}
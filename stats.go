@@ -0,0 +1,261 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PlayerResult is how one signed-in player did in one finished game
+type PlayerResult struct {
+	PlayerID    string    `json:"playerId"`
+	DisplayName string    `json:"displayName"`
+	Room        string    `json:"room"`
+	Score       float64   `json:"score"`
+	Won         bool      `json:"won"` // ties for first are not wins
+	WordsFound  int       `json:"wordsFound"`
+	ValidWords  int       `json:"validWords"` // words on the board
+	BestWord    string    `json:"bestWord,omitempty"`
+	FinishedAt  time.Time `json:"finishedAt"`
+}
+
+// PlayerStats aggregates a player's results over a time window
+type PlayerStats struct {
+	PlayerID     string  `json:"playerId"`
+	DisplayName  string  `json:"displayName"`
+	GamesPlayed  int     `json:"gamesPlayed"`
+	Wins         int     `json:"wins"`
+	AverageScore float64 `json:"averageScore"`
+	BestWord     string  `json:"bestWord,omitempty"`
+	PercentFound float64 `json:"percentFound"` // share of all words on their boards they found
+}
+
+// StatsStore keeps the results of finished games
+type StatsStore interface {
+	Record(results []PlayerResult) error
+	// Results returns every result finished at or after since
+	Results(since time.Time) ([]PlayerResult, error)
+}
+
+// statsStore is chosen by newStatsStore at startup
+var statsStore StatsStore = newJSONStatsStore("")
+
+func newStatsStore(cfg Config) (StatsStore, error) {
+	store := newJSONStatsStore(cfg.StatsFile)
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// jsonStatsStore keeps results in memory and, when given a path, rewrites them
+// to a JSON file after every game
+type jsonStatsStore struct {
+	path    string
+	results []PlayerResult
+	lock    sync.RWMutex
+}
+
+func newJSONStatsStore(path string) *jsonStatsStore {
+	return &jsonStatsStore{path: path}
+}
+
+func (s *jsonStatsStore) load() error {
+	return loadJSONFile(s.path, &s.results)
+}
+
+func (s *jsonStatsStore) Record(results []PlayerResult) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.results = append(s.results, results...)
+
+	return saveJSONFile(s.path, s.results, 0644)
+}
+
+func (s *jsonStatsStore) Results(since time.Time) ([]PlayerResult, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := []PlayerResult{}
+	for _, result := range s.results {
+		if !result.FinishedAt.Before(since) {
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// betterWord reports whether a beats b as a best word: more points, then longer,
+// then alphabetically first
+func betterWord(a string, b string) bool {
+	if b == "" {
+		return true
+	}
+
+	if scoreWord(a) != scoreWord(b) {
+		return scoreWord(a) > scoreWord(b)
+	}
+
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+
+	return a < b
+}
+
+// wordsBySeat returns the words each seat found, in seat order
+func wordsBySeat(room *Room) [][]string {
+	words := make([][]string, len(room.Seats))
+
+	if room.Mode == MODE_SIMULTANEOUS {
+		for i, seat := range room.Seats {
+			words[i] = seat.Words
+		}
+
+		return words
+	}
+
+	for word, player := range room.FoundWords {
+		words[player-1] = append(words[player-1], word)
+	}
+
+	return words
+}
+
+// recordStats saves the result of a finished game for every signed-in player
+func recordStats(room *Room) {
+	found := wordsBySeat(room)
+	finishedAt := time.Now().UTC()
+
+	top, leaders := 0.0, 0
+	for _, seat := range room.Seats {
+		if seat.Score > top {
+			top, leaders = seat.Score, 1
+		} else if seat.Score == top {
+			leaders++
+		}
+	}
+
+	results := []PlayerResult{}
+
+	for i, seat := range room.Seats {
+		if seat.PlayerID == "" {
+			continue
+		}
+
+		result := PlayerResult{
+			PlayerID:    seat.PlayerID,
+			DisplayName: seat.DisplayName,
+			Room:        room.RoomName,
+			Score:       seat.Score,
+			Won:         seat.Score == top && leaders == 1 && top > 0,
+			WordsFound:  len(found[i]),
+			ValidWords:  len(room.AllValidWords),
+			FinishedAt:  finishedAt,
+		}
+
+		for _, word := range found[i] {
+			if betterWord(word, result.BestWord) {
+				result.BestWord = word
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		return
+	}
+
+	if err := statsStore.Record(results); err != nil {
+		log.Printf("failed to record stats for %s: %s\n", room.RoomName, err.Error())
+	}
+}
+
+// windowStart turns a window name into the earliest time it covers
+func windowStart(window string, now time.Time) (time.Time, bool) {
+	switch window {
+	case "daily":
+		return now.Add(-24 * time.Hour), true
+	case "weekly":
+		return now.Add(-7 * 24 * time.Hour), true
+	case "all", "":
+		return time.Time{}, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// aggregateStats folds results into one PlayerStats per player
+func aggregateStats(results []PlayerResult) map[string]*PlayerStats {
+	all := make(map[string]*PlayerStats)
+	totals := make(map[string]float64)
+	found := make(map[string]int)
+	valid := make(map[string]int)
+
+	for _, result := range results {
+		stats, exists := all[result.PlayerID]
+		if !exists {
+			stats = &PlayerStats{PlayerID: result.PlayerID}
+			all[result.PlayerID] = stats
+		}
+
+		// results are in the order games finished, so this ends on the latest name
+		stats.DisplayName = result.DisplayName
+		stats.GamesPlayed++
+
+		if result.Won {
+			stats.Wins++
+		}
+
+		if result.BestWord != "" && betterWord(result.BestWord, stats.BestWord) {
+			stats.BestWord = result.BestWord
+		}
+
+		totals[result.PlayerID] += result.Score
+		found[result.PlayerID] += result.WordsFound
+		valid[result.PlayerID] += result.ValidWords
+	}
+
+	for id, stats := range all {
+		stats.AverageScore = totals[id] / float64(stats.GamesPlayed)
+
+		if valid[id] > 0 {
+			stats.PercentFound = 100 * float64(found[id]) / float64(valid[id])
+		}
+	}
+
+	return all
+}
+
+// leaderboard ranks players by wins, then average score, then games played
+func leaderboard(results []PlayerResult) []*PlayerStats {
+	ranked := []*PlayerStats{}
+	for _, stats := range aggregateStats(results) {
+		ranked = append(ranked, stats)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+
+		if a.AverageScore != b.AverageScore {
+			return a.AverageScore > b.AverageScore
+		}
+
+		if a.GamesPlayed != b.GamesPlayed {
+			return a.GamesPlayed > b.GamesPlayed
+		}
+
+		return a.PlayerID < b.PlayerID
+	})
+
+	return ranked
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_LEADERBOARD_SIZE = 25
+	MAX_LEADERBOARD_SIZE     = 100
+)
+
+type LeaderboardResponse struct {
+	Window  string         `json:"window"`
+	Players []*PlayerStats `json:"players"`
+}
+
+type PlayerStatsResponse struct {
+	Window string       `json:"window"`
+	Stats  *PlayerStats `json:"stats"`
+	Rating Rating       `json:"rating"` // current rating, whatever the window
+}
+
+// loadResults reads the window query parameter and loads the results in it,
+// writing an error response and returning false if that fails
+func loadResults(w http.ResponseWriter, r *http.Request) (string, []PlayerResult, bool) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeHTTPError(w, http.StatusMethodNotAllowed, "methodNotAllowed", "use GET")
+		return "", nil, false
+	}
+
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "all"
+	}
+
+	since, ok := windowStart(window, time.Now().UTC())
+	if !ok {
+		writeHTTPError(w, http.StatusBadRequest, "invalidWindow", "window must be daily, weekly or all")
+		return "", nil, false
+	}
+
+	results, err := statsStore.Results(since)
+	if err != nil {
+		log.Printf("failed to load stats: %s\n", err.Error())
+		writeHTTPError(w, http.StatusInternalServerError, "internalError", "could not load stats")
+		return "", nil, false
+	}
+
+	return window, results, true
+}
+
+// handleLeaderboard serves GET /leaderboard?window=daily|weekly|all&limit=N
+func handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	window, results, ok := loadResults(w, r)
+	if !ok {
+		return
+	}
+
+	limit := DEFAULT_LEADERBOARD_SIZE
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > MAX_LEADERBOARD_SIZE {
+			writeHTTPError(w, http.StatusBadRequest, "invalidLimit", "limit must be between 1 and 100")
+			return
+		}
+
+		limit = parsed
+	}
+
+	ranked := leaderboard(results)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, LeaderboardResponse{Window: window, Players: ranked})
+}
+
+// handlePlayers serves GET /players/{id}/stats?window=daily|weekly|all
+func handlePlayers(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/players/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "stats" {
+		writeHTTPError(w, http.StatusNotFound, "notFound", "unknown player endpoint")
+		return
+	}
+
+	account, err := accountStore.ByID(parts[0])
+	if err != nil {
+		writeHTTPError(w, http.StatusNotFound, "unknownPlayer", "no player with that id")
+		return
+	}
+
+	window, results, ok := loadResults(w, r)
+	if !ok {
+		return
+	}
+
+	stats, played := aggregateStats(results)[account.ID]
+	if !played {
+		stats = &PlayerStats{PlayerID: account.ID, DisplayName: account.DisplayName}
+	}
+
+	rating, err := ratingStore.Get(account.ID)
+	if err != nil {
+		log.Printf("failed to load rating of %s: %s\n", account.ID, err.Error())
+		writeHTTPError(w, http.StatusInternalServerError, "internalError", "could not load rating")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PlayerStatsResponse{Window: window, Stats: stats, Rating: rating})
+}
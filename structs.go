@@ -1,41 +1,167 @@
 package main
 
 import (
+	"context"
 	"sync"
-	"github.com/segmentio/kafka-go"
+	"time"
 )
 
 type Tile struct {
 	I, J int
 }
 
+// PathError describes the first step of a submitted tile path that failed verification
+type PathError struct {
+	Step   int    `json:"step"`
+	Reason string `json:"reason"`
+}
+
+// Seat is one player's place in a room. Player numbers are 1-based seat indexes
+type Seat struct {
+	Client      *WSClient   `json:"-"` // the connection currently holding the seat
+	PlayerID    string // account id, empty for anonymous players
+	DisplayName string
+	Score       float64
+	MissedTurns int
+	Words       []string    `json:"-"` // words found so far in a simultaneous game
+	Token       string      `json:"-"` // resume token, only ever sent to the seat's own player
+	ResumeTimer *time.Timer `json:"-"` // running grace window while the player is disconnected
+	SeriesIndex int         `json:"-"` // the player's index in the room's Series
+}
+
 type Room struct {
 	AllCharacters []string
 	AllValidWords []string
 	TotalScore    int
-	Player1       float64 // score for player 1
-	Player2       float64 // score for player 2
-	Player1WS     *WSClient
-	Player2WS 	  *WSClient
-	Countdown     [2]int
+	Seats         []*Seat // in turn order
+	Capacity      int     // game starts once this many players have joined
+	Spectators    []*WSClient `json:"-"` // read-only connections watching the game
+	Countdown     [2]int // length of a turn (or a whole simultaneous game) as [minutes, seconds]
+	CurrentPlayer int    // number of the player whose turn it is, 0 in simultaneous games
+	TurnDeadline  time.Time
+	StopTimer     context.CancelFunc `json:"-"` // stops the turn timer, safe to call more than once
 	RoomLock      *sync.Mutex
 	RoomName      string
-	Player1MissedTurns int
-	Player2MissedTurns int
-	KafkaWriter    *kafka.Writer `json:"-"` // add this so KafkaWriter does not get JSON serialized
+	Mode          string // one of MODES
+	Dictionary    string // name of the dictionary in boards.Dictionaries
+	Board         string // name of the board version in BOARD_VERSIONS
+	Size          int    // board is Size x Size tiles
+	Seed          int64  // seed the board was generated from
+	FoundWords    map[string]int // word -> number of the player who claimed it
+	EventSequence int64 `json:"-"` // sequence number of the last published event, updated atomically
+	Breakdown     [][]WordResult `json:"-"` // per-word results of a finished simultaneous game
+	Private       bool   `json:"-"` // joining takes the passcode or an invite, even once invites run out
+	Passcode      string `json:"-"` // required to join or watch, empty for open or invite-only rooms
+	Invites       map[string]bool `json:"-"` // every invite token issued for the room -> still unused
+	Series        *Series `json:"-"` // best-of series or rematches the game is part of, nil for a single game
 }
 
+// GameSettings are the options a client picks when creating or queueing for a game
+type GameSettings struct {
+	Dictionary string
+	Board      string
+	Seed       int64
+	Seeded     bool // false means a fresh seed is picked for the game
+	Capacity   int  // number of players, between MIN_PLAYERS and MAX_PLAYERS
+	Mode       string
+	Timer      int    // seconds per turn, or for the whole simultaneous game
+	Passcode   string // private rooms only
+	Invites    int    // private rooms only
+	BestOf     int    // length of the series started by newGame, 0 for a single game
+}
+
+// Inbound messages. Every message starts with its type, which is all the
+// Envelope reads before the message is routed to its handler
+
+type Envelope struct {
+	Type string `json:"type"`
+}
+
+// HelloMessage opens the handshake, declaring what the client speaks
+type HelloMessage struct {
+	Type     string   `json:"type"`
+	Version  int      `json:"version"`
+	Features []string `json:"features"`
+}
+
+// GameOptions are the settings shared by newGame and randomGame, all optional
+type GameOptions struct {
+	Dictionary string `json:"dictionary"`
+	Board      string `json:"board"`
+	Mode       string `json:"mode"`
+	Players    *int   `json:"players"`
+	Timer      *int   `json:"timer"` // seconds per turn, or for the whole simultaneous game
+}
+
+// JoinGameMessage needs Passcode or one of the room's Invite tokens if the room is private
 type JoinGameMessage struct {
-	Type string
-	RoomName string
+	Type     string `json:"type"`
+	RoomName string `json:"roomName"`
+	Passcode string `json:"passcode"`
+	Invite   string `json:"invite"`
 }
 
 type NewGameMessage struct {
-	Type string
+	Type string `json:"type"`
+	GameOptions
+	Seed     *int64 `json:"seed"`
+	Passcode string `json:"passcode"` // makes the room private when set
+	Invites  int    `json:"invites"`  // number of single-use invite tokens to generate
+	BestOf   int    `json:"bestOf"`   // plays a series of this many boards when above 1
+}
+
+type RandomGameMessage struct {
+	Type string `json:"type"`
+	GameOptions
 }
 
 type SubmitWordMessage struct {
-	Type string
-	Word string
-	Score float64
-}
\ No newline at end of file
+	Type string `json:"type"`
+	Word string `json:"word"`
+	Path []Tile `json:"path"`
+}
+
+// SpectateGameMessage needs Passcode or one of the room's Invite tokens if the room is private
+type SpectateGameMessage struct {
+	Type     string `json:"type"`
+	RoomName string `json:"roomName"`
+	Passcode string `json:"passcode"`
+	Invite   string `json:"invite"`
+}
+
+type ResumeMessage struct {
+	Type     string `json:"type"`
+	RoomName string `json:"roomName"`
+	Token    string `json:"token"`
+}
+
+// ReplayMessage asks for the events of a finished game to be played back.
+// Speed defaults to 1, real time
+type ReplayMessage struct {
+	Type   string   `json:"type"`
+	GameID string   `json:"gameId"`
+	Speed  *float64 `json:"speed"`
+}
+
+// RequestRematchMessage proposes playing again after a game ends. Settings left
+// out stay as they were, and the number of players cannot change
+type RequestRematchMessage struct {
+	Type string `json:"type"`
+	GameOptions
+	SwapFirst bool `json:"swapFirst"` // whoever went first goes last
+}
+
+type AcceptRematchMessage struct {
+	Type string `json:"type"`
+}
+
+// CancelRandomMessage takes the client out of the random game queue
+type CancelRandomMessage struct {
+	Type string `json:"type"`
+}
+
+// AuthenticateMessage signs the connection in with a session token from /auth
+type AuthenticateMessage struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
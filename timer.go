@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// bounds and default for the length of a turn, or of a whole simultaneous game, in seconds
+const (
+	MIN_TIMER     = 30
+	MAX_TIMER     = 600
+	DEFAULT_TIMER = 180
+)
+
+// turnDuration converts the room's [minutes, seconds] countdown into a duration
+func turnDuration(room *Room) time.Duration {
+	return time.Duration(room.Countdown[0])*time.Minute + time.Duration(room.Countdown[1])*time.Second
+}
+
+// countdownFor formats a remaining duration the same way as Room.Countdown
+func countdownFor(remaining time.Duration) [2]int {
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	seconds := int(remaining.Round(time.Second) / time.Second)
+	return [2]int{seconds / 60, seconds % 60}
+}
+
+// startTurnTimer gives the current player a fresh turn and starts the goroutine
+// that counts it down. The timer runs until room.StopTimer is called
+func startTurnTimer(room *Room) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	room.RoomLock.Lock()
+	room.TurnDeadline = time.Now().Add(turnDuration(room))
+	room.StopTimer = cancel
+	room.RoomLock.Unlock()
+
+	go runTurnTimer(ctx, room)
+}
+
+func runTurnTimer(ctx context.Context, room *Room) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		room.RoomLock.Lock()
+
+		player := room.CurrentPlayer
+		remaining := time.Until(room.TurnDeadline)
+
+		if remaining > 0 {
+			room.RoomLock.Unlock()
+			broadcastTick(room.RoomName, player, countdownFor(remaining))
+			continue
+		}
+
+		// a simultaneous game is played against a single clock
+		if room.Mode == MODE_SIMULTANEOUS {
+			room.Breakdown = scoreSimultaneous(room)
+			room.RoomLock.Unlock()
+
+			broadcastEndGame(room)
+			return
+		}
+
+		// time is up, so this counts exactly like the player passing
+		next, gameOver := finishTurn(room, "")
+
+		room.RoomLock.Unlock()
+
+		afterTurn(room, next, "", nil, gameOver)
+	}
+}
+
+// finishTurn records the end of the current player's turn, hands the turn to the
+// next seat and resets the deadline. It reports the next player and whether
+// the game is over. Caller must hold room.RoomLock
+func finishTurn(room *Room, word string) (int, bool) {
+	player := room.CurrentPlayer
+	current := room.Seats[player-1]
+	if word == "" {
+		current.MissedTurns += 1
+	} else {
+		current.MissedTurns = 0
+	}
+
+	room.CurrentPlayer = room.CurrentPlayer%len(room.Seats) + 1
+
+	// accepted words are published as they are scored
+	if word == "" {
+		publishEvent(room, EVENT_TURN_SKIPPED, player, TurnSkippedEvent{Next: room.CurrentPlayer})
+	}
+	room.TurnDeadline = time.Now().Add(turnDuration(room))
+
+	gameOver := missedTurnsPlayer(room) != 0 || totalScored(room) == float64(room.TotalScore)
+
+	return room.CurrentPlayer, gameOver
+}
+
+// afterTurn tells every player how a turn ended, or ends the game
+func afterTurn(room *Room, next int, word string, path []Tile, gameOver bool) {
+	if gameOver {
+		broadcastEndGame(room)
+		return
+	}
+
+	broadcastSwitch(room.RoomName, next, word, path)
+}
@@ -11,17 +11,31 @@ type Node struct {
 // Trie represents the trie data structure
 type Trie struct {
     root   *Node
+    nodes  int
     OFFSET int
 }
 
+// Lookup is the read-only view of a Trie, safe to share between goroutines
+// once the Trie is fully built
+type Lookup interface {
+    ContainsWord(s string) bool
+    ContainsPrefix(s string) bool
+}
+
 // NewTrie creates and returns a new Trie
 func NewTrie() *Trie {
     return &Trie{
         root:   &Node{},
+        nodes:  1,
         OFFSET: 65,
     }
 }
 
+// NodeCount returns the number of nodes in the Trie, including the root
+func (t *Trie) NodeCount() int {
+    return t.nodes
+}
+
 // Add adds a string to the Trie
 func (t *Trie) Add(s string) {
     t.root = t.add2(t.root, s, 0)
@@ -61,6 +75,7 @@ func (t *Trie) get(x *Node, s string, d int) *Node {
 func (t *Trie) add2(x *Node, s string, d int) *Node {
     if x == nil {
         x = &Node{}
+        t.nodes++
     }
     if d == len(s) {
         x.IsLast = true
@@ -1,30 +1,30 @@
 package main
 
 import (
-	"context"
+	"encoding/json"
 	"go_boggle_server/trie"
 	"log"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
-
-	"github.com/segmentio/kafka-go"
 )
 
-// change this endpoint depending on where server is (ex: localhost:9094)
-var endpoint string = "37.117.12.142:9094"
+// seeds stay below 2^53 so they survive a round trip through a JavaScript number
+const MAX_SEED = 1 << 53
 
 func startGame(room *Room) {
 	roomName := room.RoomName
 	broadcastStart(roomName)
+	startTurnTimer(room)
 }
 
 
-func findAllValidWords(constGrid [][]string, trie *trie.Trie) []string {
+func findAllValidWords(constGrid [][]string, trie trie.Lookup) []string {
 	words := []string{}
 
-	for i := 0; i < NUM; i++ {
-		for j := 0; j < NUM; j++ {
+	for i := 0; i < len(constGrid); i++ {
+		for j := 0; j < len(constGrid); j++ {
 			newWords := dfs(i, j, constGrid, trie)
 
 			for _, word := range newWords {
@@ -38,30 +38,23 @@ func findAllValidWords(constGrid [][]string, trie *trie.Trie) []string {
 	return words
 }
 
-func initGame(roomName string, trie *trie.Trie, random bool) {
-	constGrid := make([][]string, NUM)
-	allCharacters := []string{}
+func initGame(roomName string, settings GameSettings) {
+	version := BOARD_VERSIONS[settings.Board]
+	size := version.Size
 
-	for i := 0; i < NUM; i++ {
-		constGrid[i] = []string{}
+	seed := settings.Seed
+	if !settings.Seeded {
+		seed = rand.Int63n(MAX_SEED)
 	}
 
-	chosenBoggle := BOGGLE_1992
-	if rand.Intn(2) == 0 {
-		chosenBoggle = BOGGLE_1983
-	}
+	allCharacters := boardGenerator.Generate(version, rand.New(rand.NewSource(seed)))
 
-	for i := 0; i < NUM*NUM; i++ {
-		randIndex := rand.Intn(6)
-		char := chosenBoggle[i][randIndex : randIndex+1]
-		if char == "Q" {
-			char += "u"
-		}
-		constGrid[i/NUM] = append(constGrid[i/NUM], char)
-		allCharacters = append(allCharacters, char)
+	constGrid := make([][]string, size)
+	for i := 0; i < size; i++ {
+		constGrid[i] = allCharacters[i*size : (i+1)*size]
 	}
 
-	allValidWords := findAllValidWords(constGrid, trie)
+	allValidWords := findAllValidWords(constGrid, dictionaryTries[settings.Dictionary])
 	totalScore := calculateTotalPossibleScore(allValidWords)
 
 	clientRoomsLock.Lock()
@@ -71,54 +64,64 @@ func initGame(roomName string, trie *trie.Trie, random bool) {
 		AllCharacters: allCharacters,
 		AllValidWords: allValidWords,
 		TotalScore:    totalScore,
-		Player1:       0,
-		Player2:       0,
-		Player1WS:     nil,
-		Player2WS:     nil,
-		Countdown:	   [2]int{3,0},	
+		Seats:         make([]*Seat, 0, settings.Capacity),
+		Capacity:      settings.Capacity,
+		Countdown:     countdownFor(time.Duration(settings.Timer) * time.Second),
+		CurrentPlayer: 1,
+		Mode:          settings.Mode,
 		RoomLock:      &sync.Mutex{},
 		RoomName:      roomName,
-		Player1MissedTurns: 0,
-		Player2MissedTurns: 0,
+		Dictionary:    settings.Dictionary,
+		Board:         settings.Board,
+		Size:          size,
+		Seed:          seed,
+		FoundWords:    make(map[string]int),
+		Private:       settings.Passcode != "" || settings.Invites > 0,
+		Passcode:      settings.Passcode,
+		Invites:       make(map[string]bool),
 	}
 
-	_, topic_err := kafka.DialLeader(context.Background(), "tcp", endpoint, roomName, 0) // this creates topic since the kafka config is set to auto topic creation
-	if topic_err != nil {
-		room.KafkaWriter = nil
-		log.Printf("failed to create topic: %s\n", topic_err.Error())
-	} else {
-		room.KafkaWriter = &kafka.Writer{
-			Addr:     kafka.TCP(endpoint),
-			Topic:   roomName,
-			RequiredAcks: kafka.RequireAll,
-			Async:        true,
-			BatchSize:    1,
-		}
+	for i := 0; i < settings.Invites; i++ {
+		room.Invites[randomHex(16)] = true
+	}
+
+	if room.Mode == MODE_SIMULTANEOUS {
+		room.CurrentPlayer = 0
 	}
 
+	eventSink.OpenRoom(roomName)
+
+	publishEvent(room, EVENT_GAME_CREATED, 0, GameCreatedEvent{
+		Letters:    room.AllCharacters,
+		Dictionary: room.Dictionary,
+		Board:      room.Board,
+		Size:       room.Size,
+		Seed:       room.Seed,
+		Mode:       room.Mode,
+		Capacity:   room.Capacity,
+		TotalScore: room.TotalScore,
+		Private:    room.Private,
+	})
+
 	clientRooms[roomName] = room
 
 	// fmt.Println("successfully created room!")
-
-	// if random, also add to the list
-	if(random) {
-		// fmt.Println("added to random!")
-		randomRooms = append(randomRooms, room)
-	}
 }
 
-func dfs(i, j int, constGrid [][]string, trie *trie.Trie) []string {
+func dfs(i, j int, constGrid [][]string, trie trie.Lookup) []string {
 	s := Tile{i, j}
 
-	marked := make([][]bool, NUM)
-	for i := 0; i < NUM; i++ {
-		marked[i] = make([]bool, NUM)
+	size := len(constGrid)
+
+	marked := make([][]bool, size)
+	for i := 0; i < size; i++ {
+		marked[i] = make([]bool, size)
 	}
 
 	return dfs2(s, constGrid[i][j], marked, constGrid, trie)
 }
 
-func dfs2(v Tile, prefix string, marked [][]bool, constGrid [][]string, commonTrie *trie.Trie) []string {
+func dfs2(v Tile, prefix string, marked [][]bool, constGrid [][]string, commonTrie trie.Lookup) []string {
 	marked[v.I][v.J] = true
 
 	words := []string{}
@@ -127,7 +130,7 @@ func dfs2(v Tile, prefix string, marked [][]bool, constGrid [][]string, commonTr
 		words = append(words, prefix)
 	}
 
-	for _, adj := range adj2(v.I, v.J) {
+	for _, adj := range adj2(v.I, v.J, len(constGrid)) {
 		if !marked[adj.I][adj.J] {
 			newWord := prefix + constGrid[adj.I][adj.J]
 			if commonTrie.ContainsPrefix(newWord) {
@@ -141,7 +144,7 @@ func dfs2(v Tile, prefix string, marked [][]bool, constGrid [][]string, commonTr
 	return words
 }
 
-func adj2(i, j int) []Tile {
+func adj2(i, j, size int) []Tile {
 	adj := []Tile{}
 
 	if i > 0 {
@@ -149,17 +152,17 @@ func adj2(i, j int) []Tile {
 		if j > 0 {
 			adj = append(adj, Tile{i - 1, j - 1})
 		}
-		if j < NUM-1 {
+		if j < size-1 {
 			adj = append(adj, Tile{i - 1, j + 1})
 		}
 	}
 
-	if i < NUM-1 {
+	if i < size-1 {
 		adj = append(adj, Tile{i + 1, j})
 		if j > 0 {
 			adj = append(adj, Tile{i + 1, j - 1})
 		}
-		if j < NUM-1 {
+		if j < size-1 {
 			adj = append(adj, Tile{i + 1, j + 1})
 		}
 	}
@@ -167,7 +170,7 @@ func adj2(i, j int) []Tile {
 	if j > 0 {
 		adj = append(adj, Tile{i, j - 1})
 	}
-	if j < NUM-1 {
+	if j < size-1 {
 		adj = append(adj, Tile{i, j + 1})
 	}
 
@@ -178,27 +181,104 @@ func calculateTotalPossibleScore(allValidWords []string) int {
 	total := 0
 
 	for _, word := range allValidWords {
-		switch len(word) {
-		case 3, 4:
-			total++
-		case 5:
-			total += 2
-		case 6:
-			total += 3
-		case 7:
-			total += 5
-		default:
-			total += 11
-		}
+		total += scoreWord(word)
 	}
 
 	return total
 }
 
+// scoreWord returns the points a single word is worth, using the same
+// table the total possible score is computed from
+func scoreWord(word string) int {
+	switch len(word) {
+	case 3, 4:
+		return 1
+	case 5:
+		return 2
+	case 6:
+		return 3
+	case 7:
+		return 5
+	default:
+		return 11
+	}
+}
+
+// validateWord checks a submitted word against the room's board and the words
+// already claimed. It returns the points the word is worth, or a non-empty
+// rejection reason if it cannot be accepted. Caller must hold room.RoomLock
+func validateWord(room *Room, word string) (int, string) {
+	found := false
+	for _, valid := range room.AllValidWords {
+		if strings.ToUpper(valid) == word {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return 0, "notOnBoard"
+	}
+
+	if _, claimed := room.FoundWords[word]; claimed {
+		return 0, "alreadyFound"
+	}
+
+	return scoreWord(word), ""
+}
+
+// verifyPath checks that path traces word on the room's board: every tile is on
+// the board, each step is adjacent to the previous one, no tile is reused and the
+// letters along the way spell the word. It returns nil if the path is valid
+func verifyPath(room *Room, word string, path []Tile) *PathError {
+	if len(path) == 0 {
+		return &PathError{Step: 0, Reason: "missingPath"}
+	}
+
+	used := make(map[Tile]bool)
+	spelled := ""
+
+	for step, tile := range path {
+		if tile.I < 0 || tile.I >= room.Size || tile.J < 0 || tile.J >= room.Size {
+			return &PathError{Step: step, Reason: "outOfBounds"}
+		}
+
+		if used[tile] {
+			return &PathError{Step: step, Reason: "tileReused"}
+		}
+
+		if step > 0 && !isAdjacent(path[step-1], tile, room.Size) {
+			return &PathError{Step: step, Reason: "notAdjacent"}
+		}
+
+		used[tile] = true
+		spelled += strings.ToUpper(room.AllCharacters[tile.I*room.Size+tile.J])
+
+		if !strings.HasPrefix(word, spelled) {
+			return &PathError{Step: step, Reason: "letterMismatch"}
+		}
+	}
+
+	if spelled != word {
+		return &PathError{Step: len(path) - 1, Reason: "pathTooShort"}
+	}
+
+	return nil
+}
+
+func isAdjacent(from Tile, to Tile, size int) bool {
+	for _, adj := range adj2(from.I, from.J, size) {
+		if adj == to {
+			return true
+		}
+	}
+
+	return false
+}
+
 func makeID(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-	rand.Seed(time.Now().UnixNano())
 	b := make([]byte, length)
 
 	for i := range b {
@@ -210,76 +290,92 @@ func makeID(length int) string {
 
 func numberOfClients(room *Room) int {
 	num := 0
-	if room.Player1WS != nil {
-		num++
-	}
-
-	if room.Player2WS != nil {
-		num++
+	for _, seat := range room.Seats {
+		if seat.Client != nil {
+			num++
+		}
 	}
 
 	return num
 }
 
-// helper function to find if a slice contains a string
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
+// gameInfo encodes the room for the gameInfo field of outbound messages. It is
+// encoded right away because it reads FoundWords and the seats, which change
+// as soon as the lock is released. Caller must hold room.RoomLock
+func gameInfo(room *Room) json.RawMessage {
+	encoded, err := json.Marshal(room)
+	if err != nil {
+		log.Printf("failed to encode room %s: %s\n", room.RoomName, err.Error())
+		return json.RawMessage("null")
 	}
-	return false
+
+	return encoded
 }
 
-func findRoomIndex(rooms []*Room, name string) int {
-    for i, room := range rooms {
-        if room.RoomName == name {
-            return i
-        }
-    }
+func seatInfo(seat *Seat, number int) PlayerInfo {
+	return PlayerInfo{Number: number, ID: seat.PlayerID, DisplayName: seat.DisplayName}
+}
 
-    return -1 // return -1 if not found
+// roomSettings returns the settings a room was created with, minus its seed
+// and invites, which only matter for that one room
+func roomSettings(room *Room) GameSettings {
+	return GameSettings{
+		Dictionary: room.Dictionary,
+		Board:      room.Board,
+		Capacity:   room.Capacity,
+		Mode:       room.Mode,
+		Timer:      int(turnDuration(room) / time.Second),
+		Passcode:   room.Passcode,
+	}
 }
 
-// Function to remove a Room from the list by index
-func removeRoom(rooms []*Room, index int) []*Room {
-    if index < 0 || index >= len(rooms) {
-        return rooms // return the original slice if index is out of bounds
-    }
+// players returns the public info of every player in seat order
+func players(room *Room) []PlayerInfo {
+	all := make([]PlayerInfo, len(room.Seats))
+	for i, seat := range room.Seats {
+		all[i] = seatInfo(seat, i+1)
+	}
 
-    return append(rooms[:index], rooms[index+1:]...)
+	return all
 }
 
-func popFirstRoom(rooms []*Room) ([]*Room, *Room) {
-    if len(rooms) == 0 {
-        return rooms, nil // return the original slice and nil if it's empty
-    }
-    firstRoom := rooms[0]
-    return rooms[1:], firstRoom
+// scores returns every player's score in seat order
+func scores(room *Room) []float64 {
+	all := make([]float64, len(room.Seats))
+	for i, seat := range room.Seats {
+		all[i] = seat.Score
+	}
+
+	return all
 }
 
-func sendMessage(room *Room, message string) {
-	if room.KafkaWriter == nil {
-		return
+func totalScored(room *Room) float64 {
+	total := 0.0
+	for _, seat := range room.Seats {
+		total += seat.Score
 	}
 
-	room.KafkaWriter.WriteMessages(
-		context.Background(),
-		kafka.Message{
-			Value: []byte(message),
-		},
-	)
+	return total
 }
 
-func deleteTopic(topic string) {
-	conn, err := kafka.Dial("tcp", endpoint)
-
-	if err != nil {
-		log.Println("failed to dial to remove topic " + topic)
-		return
+// missedTurnsPlayer returns the number of the first player who missed 3
+// consecutive turns, or 0 if nobody has
+func missedTurnsPlayer(room *Room) int {
+	for i, seat := range room.Seats {
+		if seat.MissedTurns == 3 {
+			return i + 1
+		}
 	}
 
-	defer conn.Close()
+	return 0
+}
 
-	conn.DeleteTopics(topic)
+// helper function to find if a slice contains a string
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+// testBoard is a 4x4 board laid out as
+//
+//	C A T S
+//	O Qu E R
+//	D O G S
+//	E A R N
+func testBoard() *Room {
+	return &Room{
+		Size: 4,
+		AllCharacters: []string{
+			"c", "a", "t", "s",
+			"o", "qu", "e", "r",
+			"d", "o", "g", "s",
+			"e", "a", "r", "n",
+		},
+	}
+}
+
+func TestVerifyPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		word   string
+		path   []Tile
+		reason string // empty when the path is valid
+		step   int
+	}{
+		{"valid", "CAT", []Tile{{0, 0}, {0, 1}, {0, 2}}, "", 0},
+		{"diagonal", "SEG", []Tile{{0, 3}, {1, 2}, {2, 2}}, "", 0},
+		{"multi letter tile", "QUOD", []Tile{{1, 1}, {2, 1}, {2, 0}}, "", 0},
+		{"half a multi letter tile", "QOD", []Tile{{1, 1}, {2, 1}, {2, 0}}, "letterMismatch", 0},
+		{"missing path", "CAT", nil, "missingPath", 0},
+		{"out of bounds", "CAT", []Tile{{0, 0}, {0, 1}, {0, 4}}, "outOfBounds", 2},
+		{"negative index", "CAT", []Tile{{-1, 0}}, "outOfBounds", 0},
+		{"tile reused", "CAC", []Tile{{0, 0}, {0, 1}, {0, 0}}, "tileReused", 2},
+		{"not adjacent", "CAS", []Tile{{0, 0}, {0, 1}, {0, 3}}, "notAdjacent", 2},
+		{"wrong letter", "CAR", []Tile{{0, 0}, {0, 1}, {0, 2}}, "letterMismatch", 2},
+		{"path too short", "CATS", []Tile{{0, 0}, {0, 1}, {0, 2}}, "pathTooShort", 2},
+	}
+
+	room := testBoard()
+
+	for _, test := range tests {
+		pathErr := verifyPath(room, test.word, test.path)
+
+		if test.reason == "" {
+			if pathErr != nil {
+				t.Errorf("%s: got %s at step %d, want no error", test.name, pathErr.Reason, pathErr.Step)
+			}
+			continue
+		}
+
+		if pathErr == nil {
+			t.Errorf("%s: got no error, want %s at step %d", test.name, test.reason, test.step)
+		} else if pathErr.Reason != test.reason || pathErr.Step != test.step {
+			t.Errorf("%s: got %s at step %d, want %s at step %d", test.name, pathErr.Reason, pathErr.Step, test.reason, test.step)
+		}
+	}
+}
@@ -2,11 +2,11 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 
-	"go_boggle_server/boards"
-	"go_boggle_server/trie"
-
 	"github.com/gorilla/websocket"
 )
 
@@ -15,6 +15,20 @@ type WSClient struct {
 	RoomName       string
 	UniqueNumber   int
 	Number         int
+	Spectating     bool // spectators watch a room but never hold a seat
+	ProtocolVersion int // agreed in the hello handshake, 0 until then
+	Features       []string // features enabled in the hello handshake
+	Account        *Account `json:"-"` // nil until the connection authenticates
+	writeLock      sync.Mutex
+}
+
+// WriteJSON sends a message to the client. Rooms write to both players from
+// several goroutines, so writes have to be serialized per connection
+func (c *WSClient) WriteJSON(v interface{}) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	return c.Conn.WriteJSON(v)
 }
 
 func (c *WSClient) HandleClient() {
@@ -28,9 +42,7 @@ func (c *WSClient) HandleClient() {
 	})
 
 	for {
-		var data map[string]interface{}
-
-		err := c.Conn.ReadJSON(&data)
+		_, payload, err := c.Conn.ReadMessage()
 		if err != nil {
 			fmt.Println(err)
 
@@ -42,61 +54,34 @@ func (c *WSClient) HandleClient() {
 			break
 		}
 
-		msgType, ok := data["type"].(string)
-		if !ok {
-			// fmt.Printf("%s is invalid type for message Type\n", msgType)
-			continue
-		}
-
-		// fmt.Println("messageType: " + msgType)
-
-		switch msgType {
-		case "newGame":
-			c.newGame(false)
-		case "joinGame":
-			c.joinGame(data["roomName"].(string))
-		case "submitWord":
-			swm := SubmitWordMessage{
-				Type: data["type"].(string),
-				Word: data["word"].(string),
-				Score: data["score"].(float64),
-			}
-
-			c.submitWord(swm)
-		case "randomGame":
-			c.randomGame()
-		default:
-			continue
-		}
+		c.route(payload)
 	}
 }
 
+// newSeat creates the seat c takes as the given player number, carrying over
+// its account if it signed in
+func (c *WSClient) newSeat(number int) *Seat {
+	seat := &Seat{
+		Client:      c,
+		Token:       makeResumeToken(),
+		DisplayName: fmt.Sprintf("Player %d", number),
+	}
 
-func (c *WSClient) newGame(random bool) {
-	var commonTrie = trie.NewTrie()
-
-	for item := range boards.Common {
-		commonTrie.Add(item)
+	if c.Account != nil {
+		seat.PlayerID = c.Account.ID
+		seat.DisplayName = c.Account.DisplayName
 	}
 
+	return seat
+}
+
+func (c *WSClient) newGame(settings GameSettings) {
 	roomName := makeID(15)
 
 	c.RoomName = roomName
 	c.Number = 1
 
-	if(!random) {
-		c.Conn.WriteJSON(map[string]string{
-			"type":     "gameCode",
-			"roomName": roomName,
-		})
-	} else {
-		c.Conn.WriteJSON(map[string]string{
-			"type":     "randomWaiting",
-			"roomName": roomName,
-		})
-	}
-
-	initGame(roomName, commonTrie, random)
+	initGame(roomName, settings)
 
 	clientRoomsLock.RLock()
 	defer clientRoomsLock.RUnlock()
@@ -106,26 +91,48 @@ func (c *WSClient) newGame(random bool) {
 		return
 	}
 
-	room.Player1WS = c
+	// the creator is seated before anyone can learn the code and join
+	room.RoomLock.Lock()
+
+	if settings.BestOf > 1 {
+		room.Series = newSeries(settings)
+	}
+
+	seat := c.newSeat(1)
+	room.Seats = append(room.Seats, seat)
 
-	c.Conn.WriteJSON(map[string]interface{}{
-		"type":   "init",
-		"number": 1,
+	gameCode := GameCodeMessage{Type: "gameCode", RoomName: roomName}
+	for invite := range room.Invites {
+		gameCode.Invites = append(gameCode.Invites, invite)
+	}
+
+	publishEvent(room, EVENT_PLAYER_JOINED, c.Number, seatInfo(seat, c.Number))
+
+	room.RoomLock.Unlock()
+
+	c.WriteJSON(gameCode)
+
+	c.WriteJSON(InitMessage{
+		Type:        "init",
+		Number:      1,
+		Capacity:    room.Capacity,
+		ResumeToken: seat.Token,
+		DisplayName: seat.DisplayName,
 	})
 
 	fmt.Printf("%d is player %d in room %s\n", c.UniqueNumber, c.Number, c.RoomName)
 }
 
 
-func (c *WSClient) joinGame(roomName string) {
+// joinGame seats c in a room that is still waiting for players. Private rooms
+// also need their passcode or an unused invite token
+func (c *WSClient) joinGame(roomName string, passcode string, invite string) {
 	clientRoomsLock.RLock()
 	defer clientRoomsLock.RUnlock()
 
 	room, exists := clientRooms[roomName]
 	if !exists {
-		c.Conn.WriteJSON(map[string]string{
-			"type": "unknownGame",
-		})
+		c.WriteJSON(StatusMessage{Type: "unknownGame"})
 		return
 	}
 
@@ -137,33 +144,50 @@ func (c *WSClient) joinGame(roomName string) {
 	if numClients == 0 || numClients == -1 {
 		room.RoomLock.Unlock()
 		// fmt.Println("Room " + roomName + " has 0 players??!")
-		c.Conn.WriteJSON(map[string]string{
-			"type": "unknownGame",
-		})
+		c.WriteJSON(StatusMessage{Type: "unknownGame"})
 		return
-	} else if numClients > 1 {
+	} else if numClients >= room.Capacity {
 		room.RoomLock.Unlock()
 		// fmt.Println("Room " + roomName + " has too many players??!")
-		c.Conn.WriteJSON(map[string]string{
-			"type": "tooManyPlayers",
-		})
+		c.WriteJSON(StatusMessage{Type: "tooManyPlayers"})
+		return
+	} else if !admit(room, passcode, invite) {
+		room.RoomLock.Unlock()
+		c.WriteJSON(StatusMessage{Type: "accessDenied"})
 		return
 	}
 
-	c.Number = 2
+	seat := c.newSeat(len(room.Seats) + 1)
+	room.Seats = append(room.Seats, seat)
+
+	c.Number = len(room.Seats)
 	c.RoomName = roomName
-	room.Player2WS = c
 
-	c.Conn.WriteJSON(map[string]interface{}{
-		"type":   "init",
-		"number": 2,
+	c.WriteJSON(InitMessage{
+		Type:        "init",
+		Number:      c.Number,
+		Capacity:    room.Capacity,
+		ResumeToken: seat.Token,
+		DisplayName: seat.DisplayName,
 	})
 
+	publishEvent(room, EVENT_PLAYER_JOINED, c.Number, seatInfo(seat, c.Number))
+
 	fmt.Printf("%d is player %d in room %s\n", c.UniqueNumber, c.Number, c.RoomName)
+
+	full := len(room.Seats) == room.Capacity
 	
 	room.RoomLock.Unlock()
 
-	startGame(room)
+	if full {
+		startGame(room)
+	} else {
+		broadcastToRoom(room, PlayerJoinedMessage{
+			Type:     "playerJoined",
+			Players:  len(room.Seats),
+			Capacity: room.Capacity,
+		})
+	}
 }
 
 func (c *WSClient) submitWord(data SubmitWordMessage) {
@@ -171,85 +195,238 @@ func (c *WSClient) submitWord(data SubmitWordMessage) {
 
 	room, exists := clientRooms[c.RoomName]
 	if !exists {
+		clientRoomsLock.RUnlock()
 		return
 	}
 
 	clientRoomsLock.RUnlock()
 
-    if c.Number == 1 {
-        // fmt.Println("Switching to player 2")
+	room.RoomLock.Lock()
 
-		if utf8.RuneCountInString(data.Word) == 0 {
-			room.Player1MissedTurns += 1
-		} else {
-			room.Player1MissedTurns = 0
-		}
+	word := strings.ToUpper(data.Word)
+
+	simultaneous := room.Mode == MODE_SIMULTANEOUS
+
+	// nobody plays until every seat is taken and the clock has started
+	started := len(room.Seats) == room.Capacity
 
-        room.Player1 = data.Score
+	// a connection that was replaced by a resume no longer holds its seat
+	seated := c.holdsSeat(room)
 
-		if room.Player1MissedTurns == 3 || room.Player1 + room.Player2 == float64(room.TotalScore) {
-			broadcastEndGame(room, room.Player1, room.Player2)
+	// the timer only notices the deadline on its next tick
+	timeUp := !room.TurnDeadline.IsZero() && !time.Now().Before(room.TurnDeadline)
+
+	if !seated || !started || (!simultaneous && room.CurrentPlayer != c.Number) || timeUp {
+		room.RoomLock.Unlock()
+
+		reason := "timeUp"
+		if c.Spectating {
+			reason = "spectator"
+		} else if !seated {
+			reason = "notSeated"
+		} else if !started {
+			reason = "notStarted"
+		} else if !simultaneous && room.CurrentPlayer != c.Number {
+			reason = "notYourTurn"
 		}
 
-        broadcastSwitch(c.RoomName, 1, 2, data.Word)
-    } else {
-        // fmt.Println("Switching to player 1")
+		c.WriteJSON(WordRejectedMessage{
+			Type:   "wordRejected",
+			Word:   word,
+			Reason: reason,
+		})
+		return
+	}
 
-		if utf8.RuneCountInString(data.Word) == 0 {
-			room.Player2MissedTurns += 1
-		} else {
-			room.Player2MissedTurns = 0
+	// there are no turns to pass in a simultaneous game
+	if simultaneous && utf8.RuneCountInString(word) == 0 {
+		room.RoomLock.Unlock()
+		return
+	}
+
+	// an empty word means the player passed their turn
+	if utf8.RuneCountInString(word) > 0 {
+		// clients without tilePaths may leave the path out, and then the word only
+		// has to be on the board, which validateWord checks
+		pathOptional := !c.hasFeature("tilePaths") && len(data.Path) == 0
+
+		if pathErr := verifyPath(room, word, data.Path); pathErr != nil && !pathOptional {
+			publishEvent(room, EVENT_WORD_REJECTED, c.Number, WordEvent{Word: word, Path: data.Path, Reason: "invalidPath"})
+			room.RoomLock.Unlock()
+
+			c.WriteJSON(WordRejectedMessage{
+				Type:      "wordRejected",
+				Word:      word,
+				Reason:    "invalidPath",
+				PathError: pathErr,
+			})
+			return
 		}
 
-        room.Player2 = data.Score
+		points, reason := validateWord(room, word)
+		if reason != "" {
+			publishEvent(room, EVENT_WORD_REJECTED, c.Number, WordEvent{Word: word, Path: data.Path, Reason: reason})
+			room.RoomLock.Unlock()
+
+			c.WriteJSON(WordRejectedMessage{
+				Type:   "wordRejected",
+				Word:   word,
+				Reason: reason,
+			})
+			return
+		}
 
-		if room.Player2MissedTurns == 3 || room.Player1 + room.Player2 == float64(room.TotalScore) {
-			broadcastEndGame(room, room.Player1, room.Player2)
+		// words are only scored once the clock runs out in a simultaneous game
+		if simultaneous {
+			c.keepSimultaneousWord(room, word, data.Path)
+			room.RoomLock.Unlock()
+			return
 		}
 
-        broadcastSwitch(c.RoomName, 2, 1, data.Word)
-    }
+		room.FoundWords[word] = c.Number
+		room.Seats[c.Number-1].Score += float64(points)
+
+		c.WriteJSON(WordAcceptedMessage{
+			Type:   "wordAccepted",
+			Word:   word,
+			Points: points,
+			Scores: scores(room),
+		})
+
+		publishEvent(room, EVENT_WORD_ACCEPTED, c.Number, WordEvent{Word: word, Path: data.Path, Points: points})
+	}
+
+	next, gameOver := finishTurn(room, word)
+
+	room.RoomLock.Unlock()
+
+	// only pass the traced path along if the word was actually accepted
+	path := data.Path
+	if utf8.RuneCountInString(word) == 0 {
+		path = nil
+	}
+
+	afterTurn(room, next, word, path, gameOver)
+}
+
+// keepSimultaneousWord records a valid word for c in a simultaneous game. Other
+// players finding the same word is fine, finding it twice yourself is not.
+// Caller must hold room.RoomLock
+func (c *WSClient) keepSimultaneousWord(room *Room, word string, path []Tile) {
+	seat := room.Seats[c.Number-1]
+
+	if contains(seat.Words, word) {
+		c.WriteJSON(WordRejectedMessage{
+			Type:   "wordRejected",
+			Word:   word,
+			Reason: "alreadyFound",
+		})
+		return
+	}
+
+	seat.Words = append(seat.Words, word)
+
+	c.WriteJSON(WordAcceptedMessage{
+		Type:  "wordAccepted",
+		Word:  word,
+		Found: len(seat.Words),
+	})
+
+	// points depend on the other players, so they are only known in GameEnded
+	publishEvent(room, EVENT_WORD_ACCEPTED, c.Number, WordEvent{Word: word, Path: path})
 }
 
 func (c *WSClient) handleDisconnect() {
+	// a connection can be queued for a random game whether or not it has a room
+	queued := matchmaker.Remove(c)
+	if queued {
+		fmt.Printf("%d left the random game queue after disconnect\n", c.UniqueNumber)
+	}
+
+	c.leaveRematch()
+
 	if c.RoomName == "" {
-		fmt.Printf("%d could not find room %s to delete after disconnect!\n", c.Number, c.RoomName)
+		if !queued {
+			fmt.Printf("%d could not find room %s to delete after disconnect!\n", c.Number, c.RoomName)
+		}
+
 		return
 	}
 
-	broadcastDisconnect(c.RoomName)
-	
-	clientRoomsLock.Lock()	
+	if c.Spectating {
+		c.stopSpectating()
+		return
+	}
 
-	delete(clientRooms, c.RoomName)
+	clientRoomsLock.RLock()
+	room, exists := clientRooms[c.RoomName]
+	clientRoomsLock.RUnlock()
 
-	clientRoomsLock.Unlock()
+	if exists {
+		seated, playing, waiting := c.leaveSeat(room)
+
+		if waiting {
+			broadcastOpponentStatus(room, c.Number, "opponentDisconnected")
+			fmt.Printf("%d disconnected from room %s, waiting for resume\n", c.UniqueNumber, c.RoomName)
+		}
+
+		// someone else holds the seat now, or the game goes on without c
+		if !seated || playing {
+			return
+		}
+	}
 
-	randomRoomsLock.Lock()
+	c.endRoom(0)
+}
 
-	// also remove from randomRooms (if exists)
-	randomRooms = removeRoom(randomRooms, findRoomIndex(randomRooms, c.RoomName))
+// leaveSeat reports whether c still held its seat in room and whether the game
+// there is on. Games in progress wait for the player to come back instead of
+// ending right away, and waiting reports whether a new grace window started
+func (c *WSClient) leaveSeat(room *Room) (seated bool, playing bool, waiting bool) {
+	room.RoomLock.Lock()
+	defer room.RoomLock.Unlock()
 
-	randomRoomsLock.Unlock()
+	if !c.holdsSeat(room) {
+		return false, false, false
+	}
 
-	fmt.Printf("%d found room %s to delete after disconnect!\n", c.Number, c.RoomName)
+	if len(room.Seats) < room.Capacity {
+		return true, false, false
+	}
+
+	if !c.startResumeGrace(room) {
+		return true, true, false
+	}
+
+	publishEvent(room, EVENT_PLAYER_DISCONNECTED, c.Number, nil)
+
+	return true, true, true
 }
 
-func (c * WSClient) randomGame() {
-	// hold lock to randomRooms
-	randomRoomsLock.Lock()
+// endRoom ends the game in c's room for everyone and forgets about the room.
+// forfeit is c's seat number if c loses the game by leaving, otherwise 0
+func (c *WSClient) endRoom(forfeit int) {
+	broadcastDisconnect(c.RoomName, forfeit)
+	
+	clientRoomsLock.Lock()	
 
-	if len(randomRooms) == 0 {
-		c.newGame(true)
-		randomRoomsLock.Unlock()
-	} else {
-		// pull from list and start random game!
-		var randomRoom *Room = nil
+	delete(clientRooms, c.RoomName)
 
-		randomRooms, randomRoom = popFirstRoom(randomRooms)
+	clientRoomsLock.Unlock()
+
+	fmt.Printf("%d found room %s to delete after disconnect!\n", c.Number, c.RoomName)
+}
 
-		randomRoomsLock.Unlock()
+// randomGame queues c for a game with the given settings against players of a
+// similar rating. Anonymous players are queued at DEFAULT_RATING
+func (c * WSClient) randomGame(settings GameSettings) {
+	rating := DEFAULT_RATING
 
-		c.joinGame(randomRoom.RoomName)
+	if c.Account != nil {
+		if current, err := ratingStore.Get(c.Account.ID); err == nil {
+			rating = current.Rating
+		}
 	}
+
+	matchmaker.Enqueue(c, settings, rating)
 }
\ No newline at end of file